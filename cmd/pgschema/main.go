@@ -0,0 +1,57 @@
+// Command pgschema dumps the schema file of a relation from a live server, so
+// its heap files can be decoded later without one.
+//
+//	pgschema -dsn postgres://localhost:5432/db -table public.t -o t.json
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/krisdiano/pgdemo/heaptuple"
+)
+
+func main() {
+	var (
+		dsn    = flag.String("dsn", "", "connection string of the server")
+		table  = flag.String("table", "", "relation to describe")
+		output = flag.String("o", "", "output file, stdout if empty")
+	)
+	flag.Parse()
+	if *dsn == "" || *table == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(*dsn, *table, *output); err != nil {
+		fmt.Fprintf(os.Stderr, "pgschema: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(dsn, table, output string) error {
+	ctx := context.Background()
+	src, err := heaptuple.ConnectCatalog(ctx, dsn)
+	if err != nil {
+		return err
+	}
+	defer src.Close(ctx)
+
+	schema, err := heaptuple.DumpSchema(ctx, src, table)
+	if err != nil {
+		return err
+	}
+
+	w := os.Stdout
+	if output != "" {
+		f, err := os.Create(output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	return heaptuple.WriteSchema(w, schema)
+}
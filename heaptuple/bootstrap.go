@@ -0,0 +1,691 @@
+package heaptuple
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unsafe"
+)
+
+// OIDs of the catalogs needed to describe a relation. They are mapped
+// relations, so their relfilenode lives in pg_filenode.map instead of
+// pg_class.
+const (
+	PgTypeRelationId      = 1247
+	PgAttributeRelationId = 1249
+	PgClassRelationId     = 1259
+)
+
+// PgNamespaceRelationId is not mapped, its relfilenode is found in pg_class.
+const (
+	PgNamespaceRelationId = 2615
+)
+
+const (
+	relMapperFileMagic = 0x592717
+)
+
+// Bootstrap descriptors of the catalogs, following the layout of
+// PostgreSQL 14. Only the prefix of each catalog needed to describe a
+// relation is kept, the trailing columns are never decoded.
+var (
+	pgClassAttrAlign = []AttrAlign{
+		{AttName: "oid", TypName: "oid", TypAlign: "i", TypLen: 4},
+		{AttName: "relname", TypName: "name", TypAlign: "c", TypLen: 64},
+		{AttName: "relnamespace", TypName: "oid", TypAlign: "i", TypLen: 4},
+		{AttName: "reltype", TypName: "oid", TypAlign: "i", TypLen: 4},
+		{AttName: "reloftype", TypName: "oid", TypAlign: "i", TypLen: 4},
+		{AttName: "relowner", TypName: "oid", TypAlign: "i", TypLen: 4},
+		{AttName: "relam", TypName: "oid", TypAlign: "i", TypLen: 4},
+		{AttName: "relfilenode", TypName: "oid", TypAlign: "i", TypLen: 4},
+		{AttName: "reltablespace", TypName: "oid", TypAlign: "i", TypLen: 4},
+		{AttName: "relpages", TypName: "int4", TypAlign: "i", TypLen: 4},
+		{AttName: "reltuples", TypName: "float4", TypAlign: "i", TypLen: 4},
+		{AttName: "relallvisible", TypName: "int4", TypAlign: "i", TypLen: 4},
+		{AttName: "reltoastrelid", TypName: "oid", TypAlign: "i", TypLen: 4},
+		{AttName: "relhasindex", TypName: "bool", TypAlign: "c", TypLen: 1},
+		{AttName: "relisshared", TypName: "bool", TypAlign: "c", TypLen: 1},
+		{AttName: "relpersistence", TypName: "char", TypAlign: "c", TypLen: 1},
+		{AttName: "relkind", TypName: "char", TypAlign: "c", TypLen: 1},
+		{AttName: "relnatts", TypName: "int2", TypAlign: "s", TypLen: 2},
+	}
+	pgAttributeAttrAlign = []AttrAlign{
+		{AttName: "attrelid", TypName: "oid", TypAlign: "i", TypLen: 4},
+		{AttName: "attname", TypName: "name", TypAlign: "c", TypLen: 64},
+		{AttName: "atttypid", TypName: "oid", TypAlign: "i", TypLen: 4},
+		{AttName: "attstattarget", TypName: "int4", TypAlign: "i", TypLen: 4},
+		{AttName: "attlen", TypName: "int2", TypAlign: "s", TypLen: 2},
+		{AttName: "attnum", TypName: "int2", TypAlign: "s", TypLen: 2},
+		{AttName: "attndims", TypName: "int4", TypAlign: "i", TypLen: 4},
+		{AttName: "attcacheoff", TypName: "int4", TypAlign: "i", TypLen: 4},
+		{AttName: "atttypmod", TypName: "int4", TypAlign: "i", TypLen: 4},
+		{AttName: "attbyval", TypName: "bool", TypAlign: "c", TypLen: 1},
+		{AttName: "attalign", TypName: "char", TypAlign: "c", TypLen: 1},
+		{AttName: "attstorage", TypName: "char", TypAlign: "c", TypLen: 1},
+		{AttName: "attcompression", TypName: "char", TypAlign: "c", TypLen: 1},
+		{AttName: "attnotnull", TypName: "bool", TypAlign: "c", TypLen: 1},
+		{AttName: "atthasdef", TypName: "bool", TypAlign: "c", TypLen: 1},
+		{AttName: "atthasmissing", TypName: "bool", TypAlign: "c", TypLen: 1},
+		{AttName: "attidentity", TypName: "char", TypAlign: "c", TypLen: 1},
+		{AttName: "attgenerated", TypName: "char", TypAlign: "c", TypLen: 1},
+		{AttName: "attisdropped", TypName: "bool", TypAlign: "c", TypLen: 1},
+		{AttName: "attislocal", TypName: "bool", TypAlign: "c", TypLen: 1},
+		{AttName: "attinhcount", TypName: "int4", TypAlign: "i", TypLen: 4},
+		{AttName: "attcollation", TypName: "oid", TypAlign: "i", TypLen: 4},
+		{AttName: "attacl", TypName: "_aclitem", TypAlign: "i", TypLen: -1},
+		{AttName: "attoptions", TypName: "_text", TypAlign: "i", TypLen: -1},
+		{AttName: "attfdwoptions", TypName: "_text", TypAlign: "i", TypLen: -1},
+		{AttName: "attmissingval", TypName: "anyarray", TypAlign: "d", TypLen: -1},
+	}
+	pgTypeAttrAlign = []AttrAlign{
+		{AttName: "oid", TypName: "oid", TypAlign: "i", TypLen: 4},
+		{AttName: "typname", TypName: "name", TypAlign: "c", TypLen: 64},
+		{AttName: "typnamespace", TypName: "oid", TypAlign: "i", TypLen: 4},
+		{AttName: "typowner", TypName: "oid", TypAlign: "i", TypLen: 4},
+		{AttName: "typlen", TypName: "int2", TypAlign: "s", TypLen: 2},
+		{AttName: "typbyval", TypName: "bool", TypAlign: "c", TypLen: 1},
+		{AttName: "typtype", TypName: "char", TypAlign: "c", TypLen: 1},
+		{AttName: "typcategory", TypName: "char", TypAlign: "c", TypLen: 1},
+		{AttName: "typispreferred", TypName: "bool", TypAlign: "c", TypLen: 1},
+		{AttName: "typisdefined", TypName: "bool", TypAlign: "c", TypLen: 1},
+		{AttName: "typdelim", TypName: "char", TypAlign: "c", TypLen: 1},
+		{AttName: "typrelid", TypName: "oid", TypAlign: "i", TypLen: 4},
+		{AttName: "typsubscript", TypName: "regproc", TypAlign: "i", TypLen: 4},
+		{AttName: "typelem", TypName: "oid", TypAlign: "i", TypLen: 4},
+		{AttName: "typarray", TypName: "oid", TypAlign: "i", TypLen: 4},
+		{AttName: "typinput", TypName: "regproc", TypAlign: "i", TypLen: 4},
+		{AttName: "typoutput", TypName: "regproc", TypAlign: "i", TypLen: 4},
+		{AttName: "typreceive", TypName: "regproc", TypAlign: "i", TypLen: 4},
+		{AttName: "typsend", TypName: "regproc", TypAlign: "i", TypLen: 4},
+		{AttName: "typmodin", TypName: "regproc", TypAlign: "i", TypLen: 4},
+		{AttName: "typmodout", TypName: "regproc", TypAlign: "i", TypLen: 4},
+		{AttName: "typanalyze", TypName: "regproc", TypAlign: "i", TypLen: 4},
+		{AttName: "typalign", TypName: "char", TypAlign: "c", TypLen: 1},
+	}
+	pgNamespaceAttrAlign = []AttrAlign{
+		{AttName: "oid", TypName: "oid", TypAlign: "i", TypLen: 4},
+		{AttName: "nspname", TypName: "name", TypAlign: "c", TypLen: 64},
+	}
+)
+
+// ClassInfo is the part of a pg_class row needed to locate a relation on disk.
+type ClassInfo struct {
+	Oid        uint32
+	Name       string
+	Namespace  uint32
+	Filenode   uint32
+	Tablespace uint32
+	ToastRelid uint32
+	IsShared   bool
+	Kind       string
+	Natts      int
+}
+
+type attributeInfo struct {
+	AttName    string
+	TypOid     uint32
+	AttNum     int
+	AttLen     int
+	AttAlign   string
+	TypMod     int32
+	ByVal      bool
+	IsDropped  bool
+	HasMissing bool
+	// MissingVal is attmissingval in its on-disk array form
+	MissingVal string
+}
+
+type typeInfo struct {
+	TypName  string
+	TypAlign string
+	TypLen   int
+}
+
+// OfflineCatalog reads pg_class, pg_namespace, pg_attribute and pg_type
+// straight from the heap files of a database, so a data directory can be
+// inspected while its server is down.
+type OfflineCatalog struct {
+	pgdata     string
+	dbOid      uint32
+	blockSize  int
+	vis        *Visibility
+	searchPath []string
+	localMap   map[uint32]uint32
+	sharedMap  map[uint32]uint32
+	classes    []ClassInfo
+	namespaces map[uint32]string
+	attributes map[uint32][]attributeInfo
+	types      map[uint32]typeInfo
+}
+
+func OpenOfflineCatalog(pgdata string, dbOid uint32) (*OfflineCatalog, error) {
+	c := &OfflineCatalog{
+		pgdata:     pgdata,
+		dbOid:      dbOid,
+		searchPath: []string{"pg_catalog", "public"},
+		namespaces: make(map[uint32]string),
+		attributes: make(map[uint32][]attributeInfo),
+		types:      make(map[uint32]typeInfo),
+	}
+
+	var err error
+	c.localMap, err = ReadRelMap(filepath.Join(c.dbDir(), "pg_filenode.map"))
+	if err != nil {
+		return nil, err
+	}
+	c.sharedMap, err = ReadRelMap(filepath.Join(pgdata, "global", "pg_filenode.map"))
+	if err != nil {
+		return nil, err
+	}
+	classPath, err := c.catalogPath(PgClassRelationId)
+	if err != nil {
+		return nil, err
+	}
+	if c.blockSize, err = readBlockSize(classPath); err != nil {
+		return nil, err
+	}
+	// without pg_xact the hint bits are all there is
+	if _, err := os.Stat(filepath.Join(pgdata, "pg_xact")); err == nil {
+		c.vis = OpenVisibility(pgdata, nil)
+	}
+
+	err = c.scanCatalog(PgClassRelationId, pgClassAttrAlign, func(kv map[string]string) error {
+		var (
+			item ClassInfo
+			err  error
+		)
+		item.Name = kv["relname"]
+		item.Kind = kv["relkind"]
+		item.IsShared = kv["relisshared"] == "t"
+		if item.Oid, err = parseOid(kv["oid"]); err != nil {
+			return err
+		}
+		if item.Namespace, err = parseOid(kv["relnamespace"]); err != nil {
+			return err
+		}
+		if item.Filenode, err = parseOid(kv["relfilenode"]); err != nil {
+			return err
+		}
+		if item.Tablespace, err = parseOid(kv["reltablespace"]); err != nil {
+			return err
+		}
+		if item.ToastRelid, err = parseOid(kv["reltoastrelid"]); err != nil {
+			return err
+		}
+		if item.Natts, err = strconv.Atoi(kv["relnatts"]); err != nil {
+			return err
+		}
+		c.classes = append(c.classes, item)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.scanCatalog(PgNamespaceRelationId, pgNamespaceAttrAlign, func(kv map[string]string) error {
+		oid, err := parseOid(kv["oid"])
+		if err != nil {
+			return err
+		}
+		c.namespaces[oid] = kv["nspname"]
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.scanCatalog(PgAttributeRelationId, pgAttributeAttrAlign, func(kv map[string]string) error {
+		var (
+			item   attributeInfo
+			relOid uint32
+			err    error
+		)
+		item.AttName = kv["attname"]
+		item.AttAlign = kv["attalign"]
+		item.MissingVal = kv["attmissingval"]
+		item.ByVal = kv["attbyval"] == "t"
+		item.IsDropped = kv["attisdropped"] == "t"
+		item.HasMissing = kv["atthasmissing"] == "t"
+		if relOid, err = parseOid(kv["attrelid"]); err != nil {
+			return err
+		}
+		if item.TypOid, err = parseOid(kv["atttypid"]); err != nil {
+			return err
+		}
+		if item.AttNum, err = strconv.Atoi(kv["attnum"]); err != nil {
+			return err
+		}
+		if item.AttLen, err = strconv.Atoi(kv["attlen"]); err != nil {
+			return err
+		}
+		if item.AttNum < 0 {
+			return nil
+		}
+		typMod, err := strconv.ParseInt(kv["atttypmod"], 10, 32)
+		if err != nil {
+			return err
+		}
+		item.TypMod = int32(typMod)
+		c.attributes[relOid] = append(c.attributes[relOid], item)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.scanCatalog(PgTypeRelationId, pgTypeAttrAlign, func(kv map[string]string) error {
+		oid, err := parseOid(kv["oid"])
+		if err != nil {
+			return err
+		}
+		typLen, err := strconv.Atoi(kv["typlen"])
+		if err != nil {
+			return err
+		}
+		c.types[oid] = typeInfo{TypName: kv["typname"], TypAlign: kv["typalign"], TypLen: typLen}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// SetSearchPath replaces the schemas searched for unqualified names, by
+// default pg_catalog then public.
+func (c *OfflineCatalog) SetSearchPath(schemas ...string) {
+	c.searchPath = schemas
+}
+
+// Lookup returns the live pg_class row of the relation named relname, which
+// is resolved like a regclass: either schema qualified or through the search
+// path.
+func (c *OfflineCatalog) Lookup(relname string) (ClassInfo, error) {
+	schema, name, err := parseQualifiedName(relname)
+	if err != nil {
+		return ClassInfo{}, err
+	}
+	schemas := c.searchPath
+	if schema != "" {
+		schemas = []string{schema}
+	}
+	for _, schema := range schemas {
+		for _, item := range c.classes {
+			if item.Name == name && c.namespaces[item.Namespace] == schema {
+				return item, nil
+			}
+		}
+	}
+	return ClassInfo{}, fmt.Errorf("relation %q does not exist", relname)
+}
+
+// QualifiedName returns the schema qualified name of item, quoted when
+// needed so that Lookup resolves it back to item.
+func (c *OfflineCatalog) QualifiedName(item ClassInfo) (string, error) {
+	schema, ok := c.namespaces[item.Namespace]
+	if !ok {
+		return "", fmt.Errorf("relation %q: namespace %d does not exist", item.Name, item.Namespace)
+	}
+	return quoteIdent(schema) + "." + quoteIdent(item.Name), nil
+}
+
+// LookupOid returns the live pg_class row of the relation whose oid is relOid.
+func (c *OfflineCatalog) LookupOid(relOid uint32) (ClassInfo, error) {
+	for _, item := range c.classes {
+		if item.Oid == relOid {
+			return item, nil
+		}
+	}
+	return ClassInfo{}, fmt.Errorf("relation with oid %d does not exist", relOid)
+}
+
+// AttrAlignsByOid returns the same descriptors getAlign queries from a live
+// server, ordered by attnum.
+func (c *OfflineCatalog) AttrAlignsByOid(relOid uint32) ([]AttrAlign, error) {
+	attrs, ok := c.attributes[relOid]
+	if !ok {
+		return nil, fmt.Errorf("relation with oid %d has no attributes", relOid)
+	}
+	attrs = append([]attributeInfo(nil), attrs...)
+	sort.Slice(attrs, func(i, j int) bool { return attrs[i].AttNum < attrs[j].AttNum })
+
+	var alignments []AttrAlign
+	for _, attr := range attrs {
+		item := AttrAlign{
+			AttName:    attr.AttName,
+			TypAlign:   attr.AttAlign,
+			TypLen:     attr.AttLen,
+			TypOid:     attr.TypOid,
+			TypByVal:   attr.ByVal,
+			TypMod:     attr.TypMod,
+			IsDropped:  attr.IsDropped,
+			HasMissing: attr.HasMissing,
+		}
+		if typ, ok := c.types[attr.TypOid]; ok {
+			item.TypName = typ.TypName
+		}
+		// a dropped attribute has no value to show, nor a type to decode it
+		if item.HasMissing && !item.IsDropped {
+			missing, err := decodeMissingVal(attr.MissingVal, item)
+			if err != nil {
+				return nil, fmt.Errorf("attribute %q: %w", item.AttName, err)
+			}
+			item.MissingVal = missing
+		}
+		alignments = append(alignments, item)
+	}
+	return alignments, nil
+}
+
+// decodeMissingVal extracts the only element of attmissingval, a one
+// dimensional array of the attribute type.
+func decodeMissingVal(array string, attr AttrAlign) (string, error) {
+	// ndim, dataoffset, elemtype, then dims and lbounds. The offsets of
+	// the array header count the 4 bytes varlena header too.
+	const arrayOverhead = 16 - 4
+	if len(array) < arrayOverhead {
+		return "", fmt.Errorf("attmissingval too short, %d bytes", len(array))
+	}
+	bins := []byte(array)
+	ndim := int(binary.LittleEndian.Uint32(bins[0:4]))
+	if ndim != 1 {
+		return "", fmt.Errorf("attmissingval has %d dimensions", ndim)
+	}
+	dataOffset := int(binary.LittleEndian.Uint32(bins[4:8]))
+	if dataOffset == 0 {
+		dataOffset = alignOffset(16+2*4*ndim, "d")
+	}
+	dataOffset -= 4
+	if dataOffset > len(bins) {
+		return "", fmt.Errorf("attmissingval data offset %d out of range", dataOffset)
+	}
+
+	attr.HasMissing = false
+	th := TupleHeader{Infomask2: 1}
+	row, err := ParseTupleData([]AttrAlign{attr}, &th, bins[dataOffset:])
+	if err != nil {
+		return "", err
+	}
+	if len(row) != 1 {
+		return "", fmt.Errorf("attmissingval of attribute %q has no value", attr.AttName)
+	}
+	// in the hex output of bytea, like getAlign gets it from the server
+	if v, ok := row[0].Value.([]byte); ok && attr.TypName == "bytea" {
+		return `\x` + hex.EncodeToString(v), nil
+	}
+	return row[0].String(), nil
+}
+
+// ClassPath returns the absolute path of the first segment of the main fork
+// of the relation.
+func (c *OfflineCatalog) ClassPath(item ClassInfo) (string, error) {
+	filenode := item.Filenode
+	if filenode == 0 {
+		relMap := c.localMap
+		if item.IsShared {
+			relMap = c.sharedMap
+		}
+		var ok bool
+		filenode, ok = relMap[item.Oid]
+		if !ok {
+			return "", fmt.Errorf("relation %q is mapped but not found in pg_filenode.map", item.Name)
+		}
+	}
+	return c.filenodePath(item.Tablespace, item.IsShared, filenode)
+}
+
+func (c *OfflineCatalog) RelationPath(ctx context.Context, table string) (string, error) {
+	item, err := c.Lookup(table)
+	if err != nil {
+		return "", err
+	}
+	return c.ClassPath(item)
+}
+
+func (c *OfflineCatalog) ToastRelation(ctx context.Context, table string) (string, error) {
+	item, err := c.Lookup(table)
+	if err != nil || item.ToastRelid == 0 {
+		return "", err
+	}
+	toast, err := c.LookupOid(item.ToastRelid)
+	if err != nil {
+		return "", err
+	}
+	return c.QualifiedName(toast)
+}
+
+func (c *OfflineCatalog) AttrAligns(ctx context.Context, table string) ([]AttrAlign, error) {
+	item, err := c.Lookup(table)
+	if err != nil {
+		return nil, err
+	}
+	return c.AttrAlignsByOid(item.Oid)
+}
+
+// BlockSize is the page size found in the first page of pg_class,
+// pg_control is not read.
+func (c *OfflineCatalog) BlockSize(ctx context.Context) (int, error) {
+	return c.blockSize, nil
+}
+
+func (c *OfflineCatalog) DataDirectory() string {
+	return c.pgdata
+}
+
+func (c *OfflineCatalog) dbDir() string {
+	return filepath.Join(c.pgdata, "base", strconv.FormatUint(uint64(c.dbOid), 10))
+}
+
+func (c *OfflineCatalog) filenodePath(tablespace uint32, shared bool, filenode uint32) (string, error) {
+	name := strconv.FormatUint(uint64(filenode), 10)
+	switch {
+	case shared:
+		return filepath.Join(c.pgdata, "global", name), nil
+	case tablespace == 0:
+		return filepath.Join(c.dbDir(), name), nil
+	}
+	// pg_tblspc/<spcoid>/PG_<major>_<catversion>/<dboid>/<filenode>
+	pattern := filepath.Join(c.pgdata, "pg_tblspc", strconv.FormatUint(uint64(tablespace), 10), "PG_*",
+		strconv.FormatUint(uint64(c.dbOid), 10))
+	dirs, err := filepath.Glob(pattern)
+	if err != nil {
+		return "", err
+	}
+	if len(dirs) != 1 {
+		return "", fmt.Errorf("tablespace %d: expected 1 directory matching %s, got %d", tablespace, pattern, len(dirs))
+	}
+	return filepath.Join(dirs[0], name), nil
+}
+
+func (c *OfflineCatalog) catalogPath(relOid uint32) (string, error) {
+	filenode, ok := c.localMap[relOid]
+	if !ok {
+		filenode = relOid
+	}
+	if item, err := c.LookupOid(relOid); err == nil && item.Filenode != 0 {
+		filenode = item.Filenode
+	}
+	return c.filenodePath(0, false, filenode)
+}
+
+func (c *OfflineCatalog) scanCatalog(relOid uint32, alignments []AttrAlign, fn func(map[string]string) error) error {
+	path, err := c.catalogPath(relOid)
+	if err != nil {
+		return err
+	}
+	rel, err := OpenRelation(path, c.blockSize)
+	if err != nil {
+		return err
+	}
+	pages := rel.Pages(MainForkNum, alignments)
+	defer pages.Close()
+	for pages.Next() {
+		p := pages.Page()
+		for idx, tp := range p.Tuples {
+			if !p.IsNormal(idx) {
+				continue
+			}
+			// the layout of every table depends on the catalogs, a damaged
+			// row cannot be ignored
+			if tp.Err != nil {
+				return fmt.Errorf("catalog %d: %w", relOid, tp.Err)
+			}
+			live, err := c.isLiveCatalogTuple(tp.Header)
+			if err != nil {
+				return fmt.Errorf("catalog %d: %w", relOid, err)
+			}
+			if !live {
+				continue
+			}
+			if err := fn(tp.Data.Strings()); err != nil {
+				return fmt.Errorf("catalog %d: %w", relOid, err)
+			}
+		}
+	}
+	// a block left partial by a crash while extending holds no row yet
+	var truncated *TruncatedPageError
+	if err := pages.Err(); err != nil && !errors.As(err, &truncated) {
+		return err
+	}
+	return nil
+}
+
+// isLiveCatalogTuple is the current committed state when pg_xact is at hand.
+// Otherwise it is approximated with the hint bits: a tuple is live unless
+// its inserter is known to have aborted or it has a deleter that is not a
+// mere locker.
+func (c *OfflineCatalog) isLiveCatalogTuple(th TupleHeader) (bool, error) {
+	if c.vis != nil {
+		return c.vis.TupleVisible(th)
+	}
+	if th.XminInvalid() {
+		return false, nil
+	}
+	return th.Xmax == 0 || th.XmaxInvalid() || th.XmaxIsLockedOnly(), nil
+}
+
+// readBlockSize returns the page size recorded in the header of the first
+// page of path.
+func readBlockSize(path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	headerBytes := make([]byte, 24)
+	if _, err := io.ReadFull(f, headerBytes); err != nil {
+		return 0, fmt.Errorf("%s: %w", path, err)
+	}
+	header := **(**PageHeader)(unsafe.Pointer(&headerBytes))
+	size := header.PageSize()
+	if size < 1024 || size&(size-1) != 0 {
+		return 0, fmt.Errorf("%s: invalid page size %d", path, size)
+	}
+	return size, nil
+}
+
+// ReadRelMap parses a pg_filenode.map file into a relation oid to filenode
+// mapping. A missing file yields an empty mapping, in which case the mapped
+// catalogs still use their oid as filenode.
+func ReadRelMap(path string) (map[uint32]uint32, error) {
+	ret := make(map[uint32]uint32)
+	bytes, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return ret, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(bytes) < 8 {
+		return nil, fmt.Errorf("%s: file too short", path)
+	}
+	magic := binary.LittleEndian.Uint32(bytes[0:4])
+	if magic != relMapperFileMagic {
+		return nil, fmt.Errorf("%s: invalid magic %#x", path, magic)
+	}
+	cnt := int(binary.LittleEndian.Uint32(bytes[4:8]))
+	if 8+cnt*8 > len(bytes) {
+		return nil, fmt.Errorf("%s: %d mappings do not fit in %d bytes", path, cnt, len(bytes))
+	}
+	for i := 0; i < cnt; i++ {
+		entry := bytes[8+i*8:]
+		ret[binary.LittleEndian.Uint32(entry[0:4])] = binary.LittleEndian.Uint32(entry[4:8])
+	}
+	return ret, nil
+}
+
+func parseOid(s string) (uint32, error) {
+	v, err := strconv.ParseUint(s, 10, 32)
+	return uint32(v), err
+}
+
+// parseQualifiedName splits a possibly schema qualified relation name the way
+// regclass input does: unquoted identifiers are folded to lower case.
+func parseQualifiedName(s string) (schema, name string, err error) {
+	var (
+		parts []string
+		ident []byte
+	)
+	for i := 0; i < len(s); i++ {
+		switch ch := s[i]; {
+		case ch == '"':
+			i++
+			for ; i < len(s); i++ {
+				if s[i] != '"' {
+					ident = append(ident, s[i])
+					continue
+				}
+				if i+1 < len(s) && s[i+1] == '"' {
+					ident = append(ident, '"')
+					i++
+					continue
+				}
+				break
+			}
+			if i == len(s) {
+				return "", "", fmt.Errorf("invalid name %q: unterminated quoted identifier", s)
+			}
+		case ch == '.':
+			parts = append(parts, string(ident))
+			ident = nil
+		case 'A' <= ch && ch <= 'Z':
+			ident = append(ident, ch+'a'-'A')
+		default:
+			ident = append(ident, ch)
+		}
+	}
+	parts = append(parts, string(ident))
+	for _, part := range parts {
+		if part == "" {
+			return "", "", fmt.Errorf("invalid name %q", s)
+		}
+	}
+	switch len(parts) {
+	case 1:
+		return "", parts[0], nil
+	case 2:
+		return parts[0], parts[1], nil
+	}
+	return "", "", fmt.Errorf("invalid name %q: too many dotted names", s)
+}
+
+func quoteIdent(ident string) string {
+	safe := ident != ""
+	for i := 0; i < len(ident) && safe; i++ {
+		ch := ident[i]
+		safe = ch >= 'a' && ch <= 'z' || ch == '_' || i > 0 && (ch >= '0' && ch <= '9' || ch == '$')
+	}
+	if safe {
+		return ident
+	}
+	return `"` + strings.ReplaceAll(ident, `"`, `""`) + `"`
+}
@@ -0,0 +1,142 @@
+package heaptuple
+
+import (
+	"context"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func catalogTuple(alignments []AttrAlign, xmax uint32, values ...interface{}) []byte {
+	return buildTuple(testTuple{
+		xmin:     3,
+		xmax:     xmax,
+		infomask: 0x0100,
+		nulls:    make([]bool, len(values)),
+		data:     encodeAttrs(alignments, values...),
+	})
+}
+
+func classTuple(xmax uint32, oid uint32, name string, namespace uint32, filenode uint32, toast uint32, natts int16) []byte {
+	return catalogTuple(pgClassAttrAlign, xmax,
+		oid, name, namespace, uint32(0), uint32(0), uint32(10), uint32(2), filenode, uint32(0),
+		int32(0), float32(-1), int32(0), toast, false, false, "p", "r", natts)
+}
+
+func namespaceTuple(oid uint32, name string) []byte {
+	return catalogTuple(pgNamespaceAttrAlign, 0, oid, name)
+}
+
+func attributeTuple(relOid uint32, name string, typOid uint32, attnum int16, attLen int16, align string, byVal bool) []byte {
+	return catalogTuple(pgAttributeAttrAlign, 0,
+		relOid, name, typOid, int32(-1), attLen, attnum, int32(0), int32(-1), int32(-1),
+		byVal, align, "p", "\x00", false, false, false, "\x00", "\x00", false)
+}
+
+func typeTuple(oid uint32, name string, typLen int16, align string) []byte {
+	return catalogTuple(pgTypeAttrAlign, 0,
+		oid, name, uint32(11), uint32(10), typLen, true, "b", "N", false, true, ",", uint32(0),
+		uint32(0), uint32(0), uint32(0), uint32(0), uint32(0), uint32(0), uint32(0), uint32(0), uint32(0), uint32(0),
+		align)
+}
+
+func TestOfflineCatalog(t *testing.T) {
+	pgdata := t.TempDir()
+	dbDir := filepath.Join(pgdata, "base", "5")
+	require.NoError(t, os.MkdirAll(dbDir, 0o755))
+
+	// a cluster built with 4kB blocks
+	const blockSize = 1024 * 4
+	write := func(name string, tuples ...[]byte) {
+		require.NoError(t, os.WriteFile(filepath.Join(dbDir, name), buildPage(blockSize, tuples...), 0o644))
+	}
+	// inserted by a transaction that aborted before any hint bit was set
+	aborted := classTuple(0, 16384, "t", 2200, 16388, 0, 2)
+	binary.LittleEndian.PutUint32(aborted, 4)
+	binary.LittleEndian.PutUint16(aborted[20:], binary.LittleEndian.Uint16(aborted[20:])&^HEAP_XMIN_COMMITTED)
+	write("1259",
+		classTuple(0, PgNamespaceRelationId, "pg_namespace", 11, 16400, 0, 4),
+		aborted,
+		classTuple(7, 16384, "t", 2200, 16385, 0, 2),
+		classTuple(0, 16384, "t", 2200, 16390, 0, 2),
+		classTuple(0, 16386, "t", 16391, 16386, 16389, 2),
+		classTuple(0, 16389, "pg_toast_16386", 99, 16389, 0, 3),
+	)
+	write("16400",
+		namespaceTuple(11, "pg_catalog"),
+		namespaceTuple(99, "pg_toast"),
+		namespaceTuple(2200, "public"),
+		namespaceTuple(16391, "Other"),
+	)
+	write("1249",
+		attributeTuple(16384, "ctid", 27, -1, 6, "s", false),
+		attributeTuple(16384, "b", 19, 2, 64, "c", false),
+		attributeTuple(16384, "a", 23, 1, 4, "i", true),
+	)
+	write("1247",
+		typeTuple(19, "name", 64, "c"),
+		typeTuple(23, "int4", 4, "i"),
+		typeTuple(27, "tid", 6, "s"),
+	)
+
+	alignments := []AttrAlign{
+		{AttName: "a", TypName: "int4", TypAlign: "i", TypLen: 4, TypOid: 23, TypByVal: true, TypMod: -1},
+		{AttName: "b", TypName: "name", TypAlign: "c", TypLen: 64, TypOid: 19, TypMod: -1},
+	}
+	write("16390", buildTuple(testTuple{xmin: 3, nulls: []bool{false, false}, data: encodeAttrs(alignments, int32(42), "hello")}))
+
+	// pg_class was being extended when the server crashed
+	classFile, err := os.OpenFile(filepath.Join(dbDir, "1259"), os.O_APPEND|os.O_WRONLY, 0)
+	require.NoError(t, err)
+	_, err = classFile.Write(make([]byte, 100))
+	require.NoError(t, err)
+	require.NoError(t, classFile.Close())
+	writeClog(t, pgdata, map[TransactionId]XidStatus{
+		3: TRANSACTION_STATUS_COMMITTED,
+		4: TRANSACTION_STATUS_ABORTED,
+		7: TRANSACTION_STATUS_COMMITTED,
+	})
+
+	catalog, err := OpenOfflineCatalog(pgdata, 5)
+	require.NoError(t, err)
+	size, err := catalog.BlockSize(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, blockSize, size)
+
+	class, err := catalog.Lookup("t")
+	require.NoError(t, err)
+	assert.EqualValues(t, 16390, class.Filenode)
+
+	path, err := catalog.ClassPath(class)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dbDir, "16390"), path)
+
+	got, err := catalog.AttrAlignsByOid(class.Oid)
+	require.NoError(t, err)
+	assert.Equal(t, alignments, got)
+
+	other, err := catalog.Lookup(`"Other".T`)
+	require.NoError(t, err)
+	assert.EqualValues(t, 16386, other.Oid)
+	_, err = catalog.Lookup("other.t")
+	assert.Error(t, err)
+
+	ctx := context.Background()
+	toast, err := catalog.ToastRelation(ctx, `"Other".t`)
+	require.NoError(t, err)
+	assert.Equal(t, "pg_toast.pg_toast_16386", toast)
+	toast, err = catalog.ToastRelation(ctx, "public.t")
+	require.NoError(t, err)
+	assert.Empty(t, toast)
+
+	table, err := NewTable(ctx, catalog, "t")
+	require.NoError(t, err)
+	tuples, err := table.GetTuples()
+	require.NoError(t, err)
+	require.Len(t, tuples, 1)
+	assert.Equal(t, map[string]string{"a": "42", "b": "hello"}, tuples[0].Strings())
+}
@@ -0,0 +1,130 @@
+package heaptuple
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// testTuple describes a heap tuple to be laid out by buildTuple.
+type testTuple struct {
+	xmin      uint32
+	xmax      uint32
+	ctid      ItemPointer
+	infomask  uint16
+	infomask2 uint16
+	nulls     []bool
+	data      []byte
+}
+
+// encodeAttrs lays out values following alignments, it only knows the fixed
+// width types used by the tests.
+func encodeAttrs(alignments []AttrAlign, values ...interface{}) []byte {
+	var ret []byte
+	for idx, v := range values {
+		padding := map[string]int{"c": 1, "s": 2, "i": 4, "d": 8}[alignments[idx].TypAlign]
+		for len(ret)%padding != 0 {
+			ret = append(ret, 0)
+		}
+		var buf [8]byte
+		switch v := v.(type) {
+		case uint32:
+			binary.LittleEndian.PutUint32(buf[:], v)
+			ret = append(ret, buf[:4]...)
+		case int32:
+			binary.LittleEndian.PutUint32(buf[:], uint32(v))
+			ret = append(ret, buf[:4]...)
+		case int16:
+			binary.LittleEndian.PutUint16(buf[:], uint16(v))
+			ret = append(ret, buf[:2]...)
+		case float32:
+			binary.LittleEndian.PutUint32(buf[:], math.Float32bits(v))
+			ret = append(ret, buf[:4]...)
+		case int64:
+			binary.LittleEndian.PutUint64(buf[:], uint64(v))
+			ret = append(ret, buf[:]...)
+		case uint64:
+			binary.LittleEndian.PutUint64(buf[:], v)
+			ret = append(ret, buf[:]...)
+		case float64:
+			binary.LittleEndian.PutUint64(buf[:], math.Float64bits(v))
+			ret = append(ret, buf[:]...)
+		case bool:
+			if v {
+				ret = append(ret, 1)
+			} else {
+				ret = append(ret, 0)
+			}
+		case string:
+			if alignments[idx].TypName == "name" {
+				name := make([]byte, 64)
+				copy(name, v)
+				ret = append(ret, name...)
+			} else {
+				ret = append(ret, v...)
+			}
+		case []byte:
+			ret = append(ret, v...)
+		default:
+			panic("unsupported test value")
+		}
+	}
+	return ret
+}
+
+func buildTuple(tt testTuple) []byte {
+	natts := len(tt.nulls)
+	hoff := 23
+	infomask := tt.infomask
+	if natts > 0 {
+		infomask |= HEAP_HASNULL
+		hoff += (natts + 7) / 8
+	}
+	if tt.xmax == 0 {
+		infomask |= HEAP_XMAX_INVALID
+	}
+	for hoff%MAXALIGN != 0 {
+		hoff++
+	}
+
+	ret := make([]byte, hoff, hoff+len(tt.data))
+	binary.LittleEndian.PutUint32(ret[0:], tt.xmin)
+	binary.LittleEndian.PutUint32(ret[4:], tt.xmax)
+	binary.LittleEndian.PutUint16(ret[12:], uint16(tt.ctid.Block>>16))
+	binary.LittleEndian.PutUint16(ret[14:], uint16(tt.ctid.Block))
+	binary.LittleEndian.PutUint16(ret[16:], tt.ctid.Offset)
+	binary.LittleEndian.PutUint16(ret[18:], uint16(natts)|tt.infomask2)
+	binary.LittleEndian.PutUint16(ret[20:], infomask)
+	ret[22] = uint8(hoff)
+	for i, isNull := range tt.nulls {
+		if !isNull {
+			ret[23+i/8] |= 1 << (i % 8)
+		}
+	}
+	return append(ret, tt.data...)
+}
+
+// buildPage assembles a heap page holding tuples, one normal line pointer
+// per tuple.
+func buildPage(pageSize int, tuples ...[]byte) []byte {
+	page := make([]byte, pageSize)
+	lower := 24 + 4*len(tuples)
+	upper := pageSize
+	for idx, tuple := range tuples {
+		upper -= len(tuple)
+		upper -= upper % MAXALIGN
+		copy(page[upper:], tuple)
+		lp := uint32(upper) | 1<<15 | uint32(len(tuple))<<17
+		binary.LittleEndian.PutUint32(page[24+4*idx:], lp)
+	}
+	binary.LittleEndian.PutUint16(page[12:], uint16(lower))
+	binary.LittleEndian.PutUint16(page[14:], uint16(upper))
+	binary.LittleEndian.PutUint16(page[16:], uint16(pageSize))
+	binary.LittleEndian.PutUint16(page[18:], uint16(pageSize)|4)
+	return page
+}
+
+// setSlot overwrites the idx-th line pointer of page.
+func setSlot(page []byte, idx int, flags LPFLAG, off, length uint16) {
+	lp := uint32(off) | uint32(flags)<<15 | uint32(length)<<17
+	binary.LittleEndian.PutUint32(page[24+4*idx:], lp)
+}
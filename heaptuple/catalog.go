@@ -0,0 +1,95 @@
+package heaptuple
+
+import (
+	"context"
+	"fmt"
+)
+
+const (
+	DefaultBlockSize = 1024 * 8
+)
+
+// CatalogSource answers where the files of a relation are and how its tuples
+// are laid out, it is all NewTable needs to know about a database.
+type CatalogSource interface {
+	// RelationPath returns the absolute path of the main fork of table.
+	RelationPath(ctx context.Context, table string) (string, error)
+	// ToastRelation returns the name of the toast relation of table, or an
+	// empty string if table has none.
+	ToastRelation(ctx context.Context, table string) (string, error)
+	// AttrAligns returns the attribute descriptors of table ordered by attnum.
+	AttrAligns(ctx context.Context, table string) ([]AttrAlign, error)
+	// BlockSize returns the size of a relation block in bytes.
+	BlockSize(ctx context.Context) (int, error)
+}
+
+// DataDirectorySource is implemented by the catalog sources that know the
+// data directory of the cluster, the tables they open only return the tuples
+// of the current committed state.
+type DataDirectorySource interface {
+	DataDirectory() string
+}
+
+// MemRelation describes one relation of a MemCatalog.
+type MemRelation struct {
+	Path       string
+	Toast      string
+	AttrAligns []AttrAlign
+}
+
+// MemCatalog is a CatalogSource whose relations are described by the caller,
+// so a Table can be built without any database at hand.
+type MemCatalog struct {
+	blockSize int
+	pgdata    string
+	relations map[string]MemRelation
+}
+
+func NewMemCatalog(blockSize int) *MemCatalog {
+	return &MemCatalog{
+		blockSize: blockSize,
+		relations: make(map[string]MemRelation),
+	}
+}
+
+// SetDataDirectory tells where the cluster of the relations is, by default
+// it is unknown and the tables return every tuple on disk.
+func (c *MemCatalog) SetDataDirectory(pgdata string) {
+	c.pgdata = pgdata
+}
+
+func (c *MemCatalog) DataDirectory() string {
+	return c.pgdata
+}
+
+// Add registers rel under name, replacing any previous description.
+func (c *MemCatalog) Add(name string, rel MemRelation) {
+	c.relations[name] = rel
+}
+
+func (c *MemCatalog) relation(table string) (MemRelation, error) {
+	rel, ok := c.relations[table]
+	if !ok {
+		return MemRelation{}, fmt.Errorf("relation %q does not exist", table)
+	}
+	return rel, nil
+}
+
+func (c *MemCatalog) RelationPath(ctx context.Context, table string) (string, error) {
+	rel, err := c.relation(table)
+	return rel.Path, err
+}
+
+func (c *MemCatalog) ToastRelation(ctx context.Context, table string) (string, error) {
+	rel, err := c.relation(table)
+	return rel.Toast, err
+}
+
+func (c *MemCatalog) AttrAligns(ctx context.Context, table string) ([]AttrAlign, error) {
+	rel, err := c.relation(table)
+	return rel.AttrAligns, err
+}
+
+func (c *MemCatalog) BlockSize(ctx context.Context) (int, error) {
+	return c.blockSize, nil
+}
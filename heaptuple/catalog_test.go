@@ -0,0 +1,39 @@
+package heaptuple
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemCatalog(t *testing.T) {
+	alignments := []AttrAlign{
+		{AttName: "id", TypName: "int4", TypAlign: "i", TypLen: 4},
+		{AttName: "relid", TypName: "oid", TypAlign: "i", TypLen: 4},
+	}
+	path := filepath.Join(t.TempDir(), "16384")
+	page := buildPage(DefaultBlockSize,
+		buildTuple(testTuple{xmin: 3, nulls: []bool{false, false}, data: encodeAttrs(alignments, int32(1), uint32(1259))}),
+		buildTuple(testTuple{xmin: 3, nulls: []bool{false, true}, data: encodeAttrs(alignments, int32(2))}),
+	)
+	require.NoError(t, os.WriteFile(path, page, 0o644))
+
+	catalog := NewMemCatalog(DefaultBlockSize)
+	catalog.Add("t", MemRelation{Path: path, AttrAligns: alignments})
+
+	_, err := NewTable(context.Background(), catalog, "missing")
+	assert.Error(t, err)
+
+	table, err := NewTable(context.Background(), catalog, "t")
+	require.NoError(t, err)
+	tuples, err := table.GetTuples()
+	require.NoError(t, err)
+	assert.Equal(t, []map[string]string{
+		{"id": "1", "relid": "1259"},
+		{"id": "2", "relid": "NULL"},
+	}, rowStrings(tuples))
+}
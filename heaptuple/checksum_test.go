@@ -0,0 +1,70 @@
+package heaptuple
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func stampChecksum(page []byte, blkno uint32) {
+	binary.LittleEndian.PutUint16(page[checksumOffset:], PageChecksum(page, blkno))
+}
+
+func TestPageChecksum(t *testing.T) {
+	alignments := []AttrAlign{{AttName: "id", TypName: "int4", TypAlign: "i", TypLen: 4}}
+	page := buildPage(DefaultBlockSize, buildTuple(testTuple{xmin: 3, nulls: []bool{false}, data: encodeAttrs(alignments, int32(1))}))
+
+	stampChecksum(page, 7)
+	require.NoError(t, VerifyPageChecksum(page, 7))
+	// the block number is part of the checksum
+	assert.Error(t, VerifyPageChecksum(page, 8))
+	// pd_checksum itself does not take part
+	checksum := binary.LittleEndian.Uint16(page[checksumOffset:])
+	assert.Equal(t, checksum, PageChecksum(page, 7))
+	assert.NotZero(t, checksum)
+
+	page[DefaultBlockSize-1] ^= 0x01
+	var checksumErr *ChecksumError
+	require.True(t, errors.As(VerifyPageChecksum(page, 7), &checksumErr))
+	assert.EqualValues(t, 7, checksumErr.Block)
+	assert.Equal(t, checksum, checksumErr.Found)
+
+	assert.NoError(t, VerifyPageChecksum(make([]byte, DefaultBlockSize), 7))
+}
+
+func TestRelationVerifyChecksums(t *testing.T) {
+	alignments := []AttrAlign{{AttName: "id", TypName: "int4", TypAlign: "i", TypLen: 4}}
+	var file []byte
+	for blkno := uint32(0); blkno < 3; blkno++ {
+		page := buildPage(DefaultBlockSize, buildTuple(testTuple{xmin: 3, nulls: []bool{false}, data: encodeAttrs(alignments, int32(blkno))}))
+		stampChecksum(page, blkno)
+		file = append(file, page...)
+	}
+	file = append(file, make([]byte, DefaultBlockSize)...)
+	file[2*DefaultBlockSize-1] ^= 0x01
+
+	path := filepath.Join(t.TempDir(), "16384")
+	require.NoError(t, os.WriteFile(path, file, 0o644))
+	rel, err := OpenRelation(path, DefaultBlockSize)
+	require.NoError(t, err)
+
+	report, err := rel.VerifyChecksums()
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.FilesScanned)
+	assert.Equal(t, 4, report.BlocksScanned)
+	assert.Equal(t, 1, report.NewPages)
+	require.Len(t, report.Failures, 1)
+	assert.EqualValues(t, 1, report.Failures[0].Block)
+	assert.Equal(t, path, report.Failures[0].Path)
+
+	hf, err := ReadHeapFileWithOptions(path, DefaultBlockSize, alignments, ReadOptions{VerifyChecksum: true})
+	var checksumErr *ChecksumError
+	require.True(t, errors.As(err, &checksumErr))
+	assert.EqualValues(t, 1, checksumErr.Block)
+	assert.Len(t, hf.Pages, 1)
+}
@@ -0,0 +1,81 @@
+package heaptuple
+
+import (
+	"path/filepath"
+)
+
+// special transaction ids, see access/transam.h
+const (
+	InvalidTransactionId     TransactionId = 0
+	BootstrapTransactionId   TransactionId = 1
+	FrozenTransactionId      TransactionId = 2
+	FirstNormalTransactionId TransactionId = 3
+)
+
+func (xid TransactionId) IsNormal() bool {
+	return xid >= FirstNormalTransactionId
+}
+
+// Precedes is TransactionIdPrecedes, normal ids compare modulo 2^32.
+func (xid TransactionId) Precedes(other TransactionId) bool {
+	if !xid.IsNormal() || !other.IsNormal() {
+		return xid < other
+	}
+	return int32(xid-other) < 0
+}
+
+// XidStatus is the 2 bits of a transaction in pg_xact.
+type XidStatus uint8
+
+const (
+	TRANSACTION_STATUS_IN_PROGRESS   XidStatus = 0x00
+	TRANSACTION_STATUS_COMMITTED     XidStatus = 0x01
+	TRANSACTION_STATUS_ABORTED       XidStatus = 0x02
+	TRANSACTION_STATUS_SUB_COMMITTED XidStatus = 0x03
+)
+
+func (s XidStatus) String() string {
+	switch s {
+	case TRANSACTION_STATUS_IN_PROGRESS:
+		return "in progress"
+	case TRANSACTION_STATUS_COMMITTED:
+		return "committed"
+	case TRANSACTION_STATUS_ABORTED:
+		return "aborted"
+	}
+	return "sub-committed"
+}
+
+const (
+	clogBitsPerXact  = 2
+	clogXactsPerByte = 4
+	clogXactsPerPage = slruPageSize * clogXactsPerByte
+)
+
+// CLOG reads the commit status of transactions from pg_xact.
+type CLOG struct {
+	slru *slru
+}
+
+// OpenCLOG reads the pg_xact directory of the cluster at pgdata.
+func OpenCLOG(pgdata string) *CLOG {
+	return &CLOG{slru: newSlru(filepath.Join(pgdata, "pg_xact"))}
+}
+
+// Status is TransactionLogFetch, the permanent ids are answered without
+// reading pg_xact.
+func (c *CLOG) Status(xid TransactionId) (XidStatus, error) {
+	switch xid {
+	case InvalidTransactionId:
+		return TRANSACTION_STATUS_ABORTED, nil
+	case BootstrapTransactionId, FrozenTransactionId:
+		return TRANSACTION_STATUS_COMMITTED, nil
+	}
+	page, err := c.slru.readPage(int64(xid / clogXactsPerPage))
+	if err != nil {
+		return 0, err
+	}
+	byteno := xid % clogXactsPerPage / clogXactsPerByte
+	bshift := xid % clogXactsPerByte * clogBitsPerXact
+	return XidStatus(page[byteno]>>bshift) & 0x03, nil
+}
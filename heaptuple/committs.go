@@ -0,0 +1,131 @@
+package heaptuple
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// postgresEpoch is the origin of the timestamps of PostgreSQL, they count
+// microseconds from it.
+var postgresEpoch = time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// timestampToTime converts microseconds since postgresEpoch, through
+// seconds as a time.Duration only spans 292 years.
+func timestampToTime(us int64) time.Time {
+	return time.Unix(postgresEpoch.Unix()+us/1e6, us%1e6*1e3).UTC()
+}
+
+// A pg_commit_ts entry is the TimestampTz of the commit then the
+// RepOriginId of the replication origin, packed.
+const (
+	sizeOfCommitTimestampEntry = 8 + 2
+	commitTsXactsPerPage       = slruPageSize / sizeOfCommitTimestampEntry
+)
+
+type CommitTimestamp struct {
+	Time time.Time
+	// Origin is the replication origin the transaction was applied from, 0
+	// for a local one.
+	Origin uint16
+}
+
+// CommitTs reads pg_commit_ts, kept when track_commit_timestamp is on.
+type CommitTs struct {
+	slru *slru
+}
+
+// OpenCommitTs reads the pg_commit_ts directory of the cluster at pgdata.
+func OpenCommitTs(pgdata string) *CommitTs {
+	return &CommitTs{slru: newSlru(filepath.Join(pgdata, "pg_commit_ts"))}
+}
+
+// Get is TransactionIdGetCommitTsData. ok is false when nothing was recorded:
+// xid did not commit, committed before track_commit_timestamp was turned on,
+// or is too old to be kept.
+func (c *CommitTs) Get(xid TransactionId) (ts CommitTimestamp, ok bool, err error) {
+	if !xid.IsNormal() {
+		return
+	}
+	page, err := c.slru.readPage(int64(xid / commitTsXactsPerPage))
+	if errors.Is(err, os.ErrNotExist) || errors.Is(err, errPageNotWritten) {
+		return ts, false, nil
+	}
+	if err != nil {
+		return
+	}
+	entry := page[xid%commitTsXactsPerPage*sizeOfCommitTimestampEntry:]
+	us := int64(binary.LittleEndian.Uint64(entry))
+	if us == 0 {
+		return
+	}
+	ts.Time = timestampToTime(us)
+	ts.Origin = binary.LittleEndian.Uint16(entry[8:])
+	return ts, true, nil
+}
+
+// The columns added to the rows of a table WithCommitTimestamps.
+const (
+	InsertedAtColumn = "inserted_at"
+	DeletedAtColumn  = "deleted_at"
+)
+
+// WithCommitTimestamps returns the table whose rows gain the inserted_at and
+// deleted_at columns, the commit times of their xmin and of their deleter.
+// They are NULL when unknown. Scans fail if the table has a column of either
+// name.
+func (t Table) WithCommitTimestamps(c *CommitTs) Table {
+	t.commitTs = c
+	return t
+}
+
+// checkCommitTsColumns fails when a column of the table would be mistaken
+// for an added one.
+func (t Table) checkCommitTsColumns() error {
+	for _, item := range t.selfAttrAlign {
+		if !item.IsDropped && (item.AttName == InsertedAtColumn || item.AttName == DeletedAtColumn) {
+			return fmt.Errorf("column %q exists, commit timestamps cannot be added", item.AttName)
+		}
+	}
+	return nil
+}
+
+func (t Table) addCommitTimestamps(row Row, th TupleHeader) (Row, error) {
+	datum := func(name string, xid TransactionId) (Datum, error) {
+		d := Datum{Name: name, Type: "timestamptz", IsNull: true}
+		if xid == InvalidTransactionId {
+			return d, nil
+		}
+		ts, ok, err := t.commitTs.Get(xid)
+		if !ok || err != nil {
+			return d, err
+		}
+		d.IsNull, d.Value = false, ts.Time.UTC()
+		return d, nil
+	}
+
+	inserted, err := datum(InsertedAtColumn, TransactionId(th.Xmin))
+	if err != nil {
+		return row, err
+	}
+	deleter := TransactionId(th.Xmax)
+	if th.XmaxInvalid() || th.XmaxIsLockedOnly() {
+		deleter = InvalidTransactionId
+	}
+	if deleter != InvalidTransactionId && th.XmaxIsMulti() {
+		m := t.multiXact()
+		if m == nil {
+			deleter = InvalidTransactionId
+		} else if deleter, err = m.UpdateXid(MultiXactId(th.Xmax)); err != nil {
+			return row, err
+		}
+	}
+	deleted, err := datum(DeletedAtColumn, deleter)
+	if err != nil {
+		return row, err
+	}
+	return append(row, inserted, deleted), nil
+}
@@ -0,0 +1,74 @@
+package heaptuple
+
+import (
+	"context"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeCommitTs lays out the first segment of pg_commit_ts under pgdata.
+func writeCommitTs(t *testing.T, pgdata string, commits map[TransactionId]CommitTimestamp) {
+	dir := filepath.Join(pgdata, "pg_commit_ts")
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+	segment := make([]byte, slruPageSize)
+	for xid, ts := range commits {
+		entry := segment[xid*sizeOfCommitTimestampEntry:]
+		binary.LittleEndian.PutUint64(entry, uint64(ts.Time.Sub(postgresEpoch)/time.Microsecond))
+		binary.LittleEndian.PutUint16(entry[8:], ts.Origin)
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "0000"), segment, 0o644))
+}
+
+func TestCommitTs(t *testing.T) {
+	pgdata := t.TempDir()
+	inserted := time.Date(2024, time.March, 1, 12, 30, 0, 250000000, time.UTC)
+	deleted := inserted.Add(time.Hour)
+	writeCommitTs(t, pgdata, map[TransactionId]CommitTimestamp{
+		10: {Time: inserted},
+		11: {Time: deleted, Origin: 3},
+	})
+	c := OpenCommitTs(pgdata)
+
+	ts, ok, err := c.Get(11)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.True(t, deleted.Equal(ts.Time))
+	assert.EqualValues(t, 3, ts.Origin)
+	for _, xid := range []TransactionId{FrozenTransactionId, 12, 5000} {
+		_, ok, err = c.Get(xid)
+		require.NoError(t, err)
+		assert.False(t, ok, "xid %d", xid)
+	}
+
+	alignments := []AttrAlign{{AttName: "id", TypName: "int4", TypAlign: "i", TypLen: 4}}
+	tuple := func(id int32, xmin, xmax uint32) []byte {
+		return buildTuple(testTuple{xmin: xmin, xmax: xmax, nulls: []bool{false}, data: encodeAttrs(alignments, id)})
+	}
+	path := filepath.Join(pgdata, "16384")
+	require.NoError(t, os.WriteFile(path, buildPage(DefaultBlockSize, tuple(1, 10, 11), tuple(2, 10, 0), tuple(3, 12, 0)), 0o644))
+	catalog := NewMemCatalog(DefaultBlockSize)
+	catalog.Add("t", MemRelation{Path: path, AttrAligns: alignments})
+	table, err := NewTable(context.Background(), catalog, "t")
+	require.NoError(t, err)
+
+	rows, err := table.WithCommitTimestamps(c).GetTuples()
+	require.NoError(t, err)
+	assert.Equal(t, []map[string]string{
+		{"id": "1", InsertedAtColumn: "2024-03-01 12:30:00.25+00", DeletedAtColumn: "2024-03-01 13:30:00.25+00"},
+		{"id": "2", InsertedAtColumn: "2024-03-01 12:30:00.25+00", DeletedAtColumn: "NULL"},
+		{"id": "3", InsertedAtColumn: "NULL", DeletedAtColumn: "NULL"},
+	}, rowStrings(rows))
+
+	// a column of the same name would be shadowed
+	catalog.Add("clash", MemRelation{Path: path, AttrAligns: []AttrAlign{{AttName: InsertedAtColumn, TypName: "int4", TypAlign: "i", TypLen: 4}}})
+	table, err = NewTable(context.Background(), catalog, "clash")
+	require.NoError(t, err)
+	_, err = table.WithCommitTimestamps(c).GetTuples()
+	assert.Error(t, err)
+}
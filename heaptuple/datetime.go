@@ -0,0 +1,535 @@
+package heaptuple
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Infinity is the value of a date, timestamp or interval equal to infinity
+// or -infinity.
+type Infinity int8
+
+const (
+	NegativeInfinity Infinity = -1
+	PositiveInfinity Infinity = 1
+)
+
+func (i Infinity) String() string {
+	if i < 0 {
+		return "-infinity"
+	}
+	return "infinity"
+}
+
+// TimeTZ is a time of day with its zone, Offset is in seconds east of UTC.
+type TimeTZ struct {
+	Time   time.Duration
+	Offset int
+}
+
+// Interval keeps months, days and time apart since their lengths vary. The
+// time is kept in microseconds, it may exceed what a time.Duration holds.
+type Interval struct {
+	Months       int32
+	Days         int32
+	Microseconds int64
+}
+
+// DateStyle is the output format of the DateStyle setting.
+type DateStyle uint8
+
+const (
+	DateStyleISO DateStyle = iota
+	DateStyleSQL
+	DateStylePostgres
+	DateStyleGerman
+)
+
+// DateOrder is the field order of the DateStyle setting, the SQL and
+// Postgres styles put the day first with DMY, the month otherwise.
+type DateOrder uint8
+
+const (
+	DateOrderMDY DateOrder = iota
+	DateOrderDMY
+	DateOrderYMD
+)
+
+// DateTimeFormat tells how dates and times are shown, the counterpart of the
+// TimeZone and DateStyle settings.
+type DateTimeFormat struct {
+	// Location is the zone timestamptz values are shown in, UTC when nil.
+	Location *time.Location
+	Style    DateStyle
+	Order    DateOrder
+}
+
+// DefaultDateTimeFormat is ISO, MDY in UTC.
+var DefaultDateTimeFormat = DateTimeFormat{Location: time.UTC}
+
+// ParseDateStyle parses a DateStyle setting like "ISO, MDY" or "German".
+func ParseDateStyle(s string) (DateStyle, DateOrder, error) {
+	style, order := DateStyleISO, DateOrderMDY
+	for _, item := range strings.Split(s, ",") {
+		switch strings.ToUpper(strings.TrimSpace(item)) {
+		case "ISO":
+			style = DateStyleISO
+		case "SQL":
+			style = DateStyleSQL
+		case "POSTGRES":
+			style = DateStylePostgres
+		case "GERMAN":
+			style = DateStyleGerman
+			order = DateOrderDMY
+		case "MDY", "US", "NONEURO", "NONEUROPEAN":
+			order = DateOrderMDY
+		case "DMY", "EURO", "EUROPEAN":
+			order = DateOrderDMY
+		case "YMD":
+			order = DateOrderYMD
+		default:
+			return 0, 0, fmt.Errorf("invalid DateStyle %q", s)
+		}
+	}
+	return style, order, nil
+}
+
+// The sentinels of DATEVAL_NOBEGIN, DATEVAL_NOEND, DT_NOBEGIN and DT_NOEND.
+const (
+	dateNoBegin      = math.MinInt32
+	dateNoEnd        = math.MaxInt32
+	timestampNoBegin = math.MinInt64
+	timestampNoEnd   = math.MaxInt64
+)
+
+// decodeDate decodes a date, the days since postgresEpoch.
+func decodeDate(data []byte) interface{} {
+	switch days := int32(binary.LittleEndian.Uint32(data)); days {
+	case dateNoBegin:
+		return NegativeInfinity
+	case dateNoEnd:
+		return PositiveInfinity
+	default:
+		return postgresEpoch.AddDate(0, 0, int(days))
+	}
+}
+
+// decodeTimestamp decodes a timestamp or a timestamptz, the microseconds
+// since postgresEpoch. A timestamp is a wall clock reading, it is kept in
+// UTC like a timestamptz.
+func decodeTimestamp(data []byte) interface{} {
+	switch us := int64(binary.LittleEndian.Uint64(data)); us {
+	case timestampNoBegin:
+		return NegativeInfinity
+	case timestampNoEnd:
+		return PositiveInfinity
+	default:
+		return timestampToTime(us)
+	}
+}
+
+func decodeTime(data []byte) interface{} {
+	return time.Duration(binary.LittleEndian.Uint64(data)) * time.Microsecond
+}
+
+func decodeTimeTZ(data []byte) interface{} {
+	return TimeTZ{
+		Time: time.Duration(binary.LittleEndian.Uint64(data)) * time.Microsecond,
+		// stored as seconds west of UTC
+		Offset: -int(int32(binary.LittleEndian.Uint32(data[8:]))),
+	}
+}
+
+func decodeInterval(data []byte) interface{} {
+	us := int64(binary.LittleEndian.Uint64(data))
+	days := int32(binary.LittleEndian.Uint32(data[8:]))
+	months := int32(binary.LittleEndian.Uint32(data[12:]))
+	switch {
+	case months == math.MinInt32 && days == math.MinInt32 && us == math.MinInt64:
+		return NegativeInfinity
+	case months == math.MaxInt32 && days == math.MaxInt32 && us == math.MaxInt64:
+		return PositiveInfinity
+	}
+	return Interval{Months: months, Days: days, Microseconds: us}
+}
+
+// parseDateTime parses the ISO text of the date and time types, as found in
+// attmissingval, into the value their decoder returns. ok is false for other
+// types.
+func parseDateTime(typName, text string) (value interface{}, ok bool, err error) {
+	switch typName {
+	case "date", "timestamp", "timestamptz", "interval":
+		switch text {
+		case "infinity":
+			return PositiveInfinity, true, nil
+		case "-infinity":
+			return NegativeInfinity, true, nil
+		}
+	}
+	fail := func() (interface{}, bool, error) {
+		return nil, true, fmt.Errorf("invalid %s %q", typName, text)
+	}
+
+	switch typName {
+	case "date":
+		t, rest, err := parseDate(text)
+		if err != nil || rest != "" {
+			return fail()
+		}
+		return t, true, nil
+	case "time":
+		clock, err := parseClock(text)
+		if err != nil {
+			return fail()
+		}
+		return clock, true, nil
+	case "timetz":
+		idx := strings.IndexAny(text, "+-")
+		if idx < 0 {
+			return fail()
+		}
+		clock, err := parseClock(text[:idx])
+		if err != nil {
+			return fail()
+		}
+		offset, err := parseOffset(text[idx:])
+		if err != nil {
+			return fail()
+		}
+		return TimeTZ{Time: clock, Offset: offset}, true, nil
+	case "timestamp", "timestamptz":
+		t, rest, err := parseDate(text)
+		if err != nil || !strings.HasPrefix(rest, " ") {
+			return fail()
+		}
+		rest = rest[1:]
+		offset := 0
+		if typName == "timestamptz" {
+			idx := strings.IndexAny(rest, "+-")
+			if idx < 0 {
+				return fail()
+			}
+			if offset, err = parseOffset(rest[idx:]); err != nil {
+				return fail()
+			}
+			rest = rest[:idx]
+		}
+		clock, err := parseClock(rest)
+		if err != nil {
+			return fail()
+		}
+		return t.Add(clock).Add(-time.Duration(offset) * time.Second), true, nil
+	case "interval":
+		v, err := parseInterval(text)
+		if err != nil {
+			return fail()
+		}
+		return v, true, nil
+	}
+	return nil, false, nil
+}
+
+// parseDate parses the YYYY-MM-DD date at the start of text, the BC suffix
+// of the ISO style is taken from its end. rest is what follows the date.
+func parseDate(text string) (t time.Time, rest string, err error) {
+	bc := strings.HasSuffix(text, " BC")
+	text = strings.TrimSuffix(text, " BC")
+	date := text
+	if idx := strings.IndexByte(text, ' '); idx >= 0 {
+		date, rest = text[:idx], text[idx:]
+	}
+	var year, month, day int
+	_, err = fmt.Sscanf(date, "%d-%d-%d", &year, &month, &day)
+	// the fields are checked by formatting them back
+	if err != nil || fmt.Sprintf("%04d-%02d-%02d", year, month, day) != date ||
+		month < 1 || month > 12 || day < 1 || day > 31 {
+		return t, "", fmt.Errorf("invalid date %q", text)
+	}
+	if bc {
+		year = 1 - year
+	}
+	t = time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+	if t.Day() != day {
+		return t, "", fmt.Errorf("invalid date %q", text)
+	}
+	return t, rest, nil
+}
+
+// parseClock parses HH:MM:SS with an optional fraction of the second.
+func parseClock(text string) (time.Duration, error) {
+	us, err := parseClockMicroseconds(text)
+	return time.Duration(us) * time.Microsecond, err
+}
+
+func parseClockMicroseconds(text string) (int64, error) {
+	parts := strings.Split(text, ":")
+	if len(parts) != 3 {
+		return 0, fmt.Errorf("invalid time %q", text)
+	}
+	hours, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	minutes, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	seconds, fraction := parts[2], ""
+	if idx := strings.IndexByte(seconds, '.'); idx >= 0 {
+		seconds, fraction = seconds[:idx], seconds[idx+1:]
+	}
+	secs, err := strconv.ParseInt(seconds, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	var us int64
+	if fraction != "" {
+		if len(fraction) > 6 {
+			return 0, fmt.Errorf("invalid time %q", text)
+		}
+		if us, err = strconv.ParseInt(fraction+strings.Repeat("0", 6-len(fraction)), 10, 64); err != nil {
+			return 0, err
+		}
+	}
+	return ((hours*60+minutes)*60+secs)*1e6 + us, nil
+}
+
+// parseOffset parses the +HH[:MM[:SS]] of formatOffset into seconds east of
+// UTC.
+func parseOffset(text string) (int, error) {
+	if len(text) < 3 || text[0] != '+' && text[0] != '-' {
+		return 0, fmt.Errorf("invalid time zone offset %q", text)
+	}
+	offset := 0
+	for idx, part := range strings.Split(text[1:], ":") {
+		if idx > 2 {
+			return 0, fmt.Errorf("invalid time zone offset %q", text)
+		}
+		v, err := strconv.Atoi(part)
+		if err != nil {
+			return 0, err
+		}
+		offset += v * []int{3600, 60, 1}[idx]
+	}
+	if text[0] == '-' {
+		offset = -offset
+	}
+	return offset, nil
+}
+
+// parseInterval parses the output of the postgres IntervalStyle.
+func parseInterval(text string) (Interval, error) {
+	var ret Interval
+	fields := strings.Fields(text)
+	for idx := 0; idx < len(fields); idx++ {
+		if strings.Contains(fields[idx], ":") {
+			clock := fields[idx]
+			negative := strings.HasPrefix(clock, "-")
+			us, err := parseClockMicroseconds(strings.TrimLeft(clock, "+-"))
+			if err != nil {
+				return ret, err
+			}
+			if negative {
+				us = -us
+			}
+			ret.Microseconds = us
+			continue
+		}
+		if idx+1 >= len(fields) {
+			return ret, fmt.Errorf("invalid interval %q", text)
+		}
+		n, err := strconv.ParseInt(fields[idx], 10, 32)
+		if err != nil {
+			return ret, err
+		}
+		idx++
+		switch strings.TrimSuffix(fields[idx], "s") {
+		case "year":
+			ret.Months += int32(n) * 12
+		case "mon":
+			ret.Months += int32(n)
+		case "day":
+			ret.Days += int32(n)
+		default:
+			return ret, fmt.Errorf("invalid interval %q", text)
+		}
+	}
+	return ret, nil
+}
+
+// Format returns the value in text form, dates and times shown as told by
+// f.
+func (d Datum) Format(f DateTimeFormat) string {
+	if d.IsNull {
+		return "NULL"
+	}
+	switch v := d.Value.(type) {
+	case time.Time:
+		switch d.Type {
+		case "date":
+			return f.date(v)
+		case "timestamp":
+			return f.timestamp(v, false)
+		}
+		return f.timestamp(v, true)
+	case time.Duration:
+		return formatClock(v)
+	case TimeTZ:
+		return formatClock(v.Time) + formatOffset(v.Offset)
+	case Interval:
+		return v.String()
+	}
+	return d.text()
+}
+
+// Format returns the text form of every datum keyed by attribute name,
+// dates and times shown as told by f.
+func (r Row) Format(f DateTimeFormat) map[string]string {
+	ret := make(map[string]string, len(r))
+	for _, d := range r {
+		ret[d.Name] = d.Format(f)
+	}
+	return ret
+}
+
+// yearBC splits a proleptic year, where 0 is 1 BC, into the year shown and
+// its era suffix.
+func yearBC(year int) (int, string) {
+	if year <= 0 {
+		return 1 - year, " BC"
+	}
+	return year, ""
+}
+
+func (f DateTimeFormat) date(t time.Time) string {
+	year, bc := yearBC(t.Year())
+	switch f.Style {
+	case DateStyleSQL:
+		if f.Order == DateOrderDMY {
+			return fmt.Sprintf("%02d/%02d/%04d%s", t.Day(), t.Month(), year, bc)
+		}
+		return fmt.Sprintf("%02d/%02d/%04d%s", t.Month(), t.Day(), year, bc)
+	case DateStylePostgres:
+		if f.Order == DateOrderDMY {
+			return fmt.Sprintf("%02d-%02d-%04d%s", t.Day(), t.Month(), year, bc)
+		}
+		return fmt.Sprintf("%02d-%02d-%04d%s", t.Month(), t.Day(), year, bc)
+	case DateStyleGerman:
+		return fmt.Sprintf("%02d.%02d.%04d%s", t.Day(), t.Month(), year, bc)
+	}
+	return fmt.Sprintf("%04d-%02d-%02d%s", year, t.Month(), t.Day(), bc)
+}
+
+// timestamp is EncodeDateTime, withZone for timestamptz which is shown in
+// the zone of f.
+func (f DateTimeFormat) timestamp(t time.Time, withZone bool) string {
+	if withZone && f.Location != nil {
+		t = t.In(f.Location)
+	}
+	year, bc := yearBC(t.Year())
+	clock := formatClock(time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute +
+		time.Duration(t.Second())*time.Second + time.Duration(t.Nanosecond()))
+	zone := ""
+	if withZone {
+		if f.Style == DateStyleISO {
+			_, offset := t.Zone()
+			zone = formatOffset(offset)
+		} else {
+			zone = " " + t.Format("MST")
+		}
+	}
+
+	switch f.Style {
+	case DateStyleSQL, DateStyleGerman:
+		return fmt.Sprintf("%s %s%s%s", f.date(time.Date(year, t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)), clock, zone, bc)
+	case DateStylePostgres:
+		day := fmt.Sprintf("%s %02d", t.Format("Jan"), t.Day())
+		if f.Order == DateOrderDMY {
+			day = fmt.Sprintf("%02d %s", t.Day(), t.Format("Jan"))
+		}
+		return fmt.Sprintf("%s %s %s %04d%s%s", t.Format("Mon"), day, clock, year, zone, bc)
+	}
+	return fmt.Sprintf("%04d-%02d-%02d %s%s%s", year, t.Month(), t.Day(), clock, zone, bc)
+}
+
+// formatClock shows a time of day as HH:MM:SS, the fraction of the second
+// without trailing zeros.
+func formatClock(d time.Duration) string {
+	us := int64(d / time.Microsecond)
+	ret := fmt.Sprintf("%02d:%02d:%02d", us/3600e6, us/60e6%60, us/1e6%60)
+	return ret + formatFraction(us%1e6)
+}
+
+func formatFraction(us int64) string {
+	if us == 0 {
+		return ""
+	}
+	return strings.TrimRight(fmt.Sprintf(".%06d", us), "0")
+}
+
+// formatOffset is EncodeTimezone, +HH with the minutes and seconds only when
+// not zero.
+func formatOffset(offset int) string {
+	sign := '+'
+	if offset < 0 {
+		sign, offset = '-', -offset
+	}
+	ret := fmt.Sprintf("%c%02d", sign, offset/3600)
+	if offset%3600 != 0 {
+		ret += fmt.Sprintf(":%02d", offset/60%60)
+	}
+	if offset%60 != 0 {
+		ret += fmt.Sprintf(":%02d", offset%60)
+	}
+	return ret
+}
+
+// String is interval_out with the postgres IntervalStyle, like
+// "1 year 2 mons -3 days 04:05:06.5".
+func (v Interval) String() string {
+	var (
+		b        strings.Builder
+		isZero   = true
+		isBefore = false
+	)
+	addPart := func(value int64, unit string) {
+		if value == 0 {
+			return
+		}
+		if !isZero {
+			b.WriteByte(' ')
+		}
+		if isBefore && value > 0 {
+			b.WriteByte('+')
+		}
+		fmt.Fprintf(&b, "%d %s", value, unit)
+		if value != 1 {
+			b.WriteByte('s')
+		}
+		isBefore, isZero = value < 0, false
+	}
+	addPart(int64(v.Months/12), "year")
+	addPart(int64(v.Months%12), "mon")
+	addPart(int64(v.Days), "day")
+
+	us := v.Microseconds
+	if !isZero && us == 0 {
+		return b.String()
+	}
+	if !isZero {
+		b.WriteByte(' ')
+	}
+	switch {
+	case us < 0:
+		b.WriteByte('-')
+		us = -us
+	case isBefore:
+		b.WriteByte('+')
+	}
+	fmt.Fprintf(&b, "%02d:%02d:%02d%s", us/3600e6, us/60e6%60, us/1e6%60, formatFraction(us%1e6))
+	return b.String()
+}
@@ -0,0 +1,146 @@
+package heaptuple
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func encodeInterval(us int64, days, months int32) []byte {
+	ret := make([]byte, 16)
+	binary.LittleEndian.PutUint64(ret, uint64(us))
+	binary.LittleEndian.PutUint32(ret[8:], uint32(days))
+	binary.LittleEndian.PutUint32(ret[12:], uint32(months))
+	return ret
+}
+
+func TestDateTimeTypes(t *testing.T) {
+	alignments := []AttrAlign{
+		{AttName: "d", TypName: "date", TypAlign: "i", TypLen: 4, TypByVal: true},
+		{AttName: "bc", TypName: "date", TypAlign: "i", TypLen: 4, TypByVal: true},
+		{AttName: "t", TypName: "time", TypAlign: "d", TypLen: 8, TypByVal: true},
+		{AttName: "ttz", TypName: "timetz", TypAlign: "d", TypLen: 12},
+		{AttName: "ts", TypName: "timestamp", TypAlign: "d", TypLen: 8, TypByVal: true},
+		{AttName: "tstz", TypName: "timestamptz", TypAlign: "d", TypLen: 8, TypByVal: true},
+		{AttName: "iv", TypName: "interval", TypAlign: "d", TypLen: 16},
+		{AttName: "neg", TypName: "interval", TypAlign: "d", TypLen: 16},
+		{AttName: "zero", TypName: "interval", TypAlign: "d", TypLen: 16},
+		{AttName: "noend", TypName: "timestamptz", TypAlign: "d", TypLen: 8, TypByVal: true},
+		{AttName: "nobegin", TypName: "date", TypAlign: "i", TypLen: 4, TypByVal: true},
+		{AttName: "ivinf", TypName: "interval", TypAlign: "d", TypLen: 16},
+	}
+	const clock = int64(4*time.Hour+5*time.Minute+6500*time.Millisecond) / 1e3
+	timetz := make([]byte, 12)
+	binary.LittleEndian.PutUint64(timetz, uint64(int64(4*time.Hour+5*time.Minute+6*time.Second)/1e3))
+	// 5:30 east, stored as seconds west
+	zone := int32(-19800)
+	binary.LittleEndian.PutUint32(timetz[8:], uint32(zone))
+	data := encodeAttrs(alignments, int32(8826), int32(-730120), clock, timetz,
+		int64(762611400250000), int64(762611400250000),
+		encodeInterval(clock, -3, 14), encodeInterval(-int64(time.Hour/1e3), 0, 1), encodeInterval(0, 0, 0),
+		int64(math.MaxInt64), int32(math.MinInt32), encodeInterval(math.MaxInt64, math.MaxInt32, math.MaxInt32))
+	th := TupleHeader{Infomask2: uint16(len(alignments))}
+	row, err := ParseTupleData(alignments, &th, data)
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]string{
+		"d":       "2024-03-01",
+		"bc":      "0001-12-31 BC",
+		"t":       "04:05:06.5",
+		"ttz":     "04:05:06+05:30",
+		"ts":      "2024-03-01 12:30:00.25",
+		"tstz":    "2024-03-01 12:30:00.25+00",
+		"iv":      "1 year 2 mons -3 days +04:05:06.5",
+		"neg":     "1 mon -01:00:00",
+		"zero":    "00:00:00",
+		"noend":   "infinity",
+		"nobegin": "-infinity",
+		"ivinf":   "infinity",
+	}, row.Strings())
+	assert.Equal(t, time.Date(2024, time.March, 1, 12, 30, 0, 250000000, time.UTC), row[5].Value)
+	assert.Equal(t, 4*time.Hour+5*time.Minute+6500*time.Millisecond, row[2].Value)
+	assert.Equal(t, Interval{Months: 14, Days: -3, Microseconds: clock}, row[6].Value)
+	assert.Equal(t, PositiveInfinity, row[9].Value)
+
+	ist := time.FixedZone("IST", 19800)
+	for _, c := range []struct {
+		format DateTimeFormat
+		ts     string
+		tstz   string
+		date   string
+	}{
+		{DateTimeFormat{Location: ist}, "2024-03-01 12:30:00.25", "2024-03-01 18:00:00.25+05:30", "2024-03-01"},
+		{DateTimeFormat{Style: DateStyleSQL}, "03/01/2024 12:30:00.25", "03/01/2024 12:30:00.25 UTC", "03/01/2024"},
+		{DateTimeFormat{Style: DateStyleSQL, Order: DateOrderDMY, Location: ist}, "01/03/2024 12:30:00.25", "01/03/2024 18:00:00.25 IST", "01/03/2024"},
+		{DateTimeFormat{Style: DateStylePostgres}, "Fri Mar 01 12:30:00.25 2024", "Fri Mar 01 12:30:00.25 2024 UTC", "03-01-2024"},
+		{DateTimeFormat{Style: DateStylePostgres, Order: DateOrderDMY}, "Fri 01 Mar 12:30:00.25 2024", "Fri 01 Mar 12:30:00.25 2024 UTC", "01-03-2024"},
+		{DateTimeFormat{Style: DateStyleGerman}, "01.03.2024 12:30:00.25", "01.03.2024 12:30:00.25 UTC", "01.03.2024"},
+	} {
+		assert.Equal(t, c.ts, row[4].Format(c.format))
+		assert.Equal(t, c.tstz, row[5].Format(c.format))
+		assert.Equal(t, c.date, row[0].Format(c.format))
+	}
+	assert.Equal(t, "0001-12-31 BC", row.Format(DateTimeFormat{Location: ist})["bc"])
+
+	// attmissingval in text form decodes to the same values
+	for idx, d := range row {
+		value, err := datumFromText(alignments[idx], d.String())
+		require.NoError(t, err, d.Name)
+		assert.Equal(t, d.Value, value, d.Name)
+	}
+	for _, c := range []struct{ typ, text string }{
+		{"date", "2024-13-01x"}, {"time", "04:05"}, {"timetz", "04:05:06"},
+		{"timestamptz", "2024-03-01 12:30:00"}, {"interval", "3 fortnights"},
+	} {
+		_, err := datumFromText(AttrAlign{TypName: c.typ}, c.text)
+		assert.Error(t, err, c.typ)
+	}
+}
+
+func TestMissingTimestamptz(t *testing.T) {
+	// created_at was added with a default after the tuple was written
+	alignments := []AttrAlign{
+		{AttName: "updated_at", TypName: "timestamptz", TypAlign: "d", TypLen: 8, TypByVal: true},
+		{AttName: "created_at", TypName: "timestamptz", TypAlign: "d", TypLen: 8, TypByVal: true,
+			HasMissing: true, MissingVal: "2024-03-01 12:30:00.25+05:30"},
+	}
+	row, err := ParseTupleData(alignments, &TupleHeader{Infomask2: 1}, encodeAttrs(alignments, int64(762611400250000)))
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2024, time.March, 1, 12, 30, 0, 250000000, time.UTC), row[0].Value)
+	assert.Equal(t, time.Date(2024, time.March, 1, 7, 0, 0, 250000000, time.UTC), row[1].Value)
+	assert.True(t, row[1].Missing)
+	assert.Equal(t, "2024-03-01 07:00:00.25+00", row[1].String())
+}
+
+func TestLongInterval(t *testing.T) {
+	// beyond the 2562047 hours of a time.Duration
+	alignments := []AttrAlign{{AttName: "iv", TypName: "interval", TypAlign: "d", TypLen: 16}}
+	const us = int64(3000000) * 3600e6
+	row, err := ParseTupleData(alignments, &TupleHeader{Infomask2: 1}, encodeInterval(-us, 0, 0))
+	require.NoError(t, err)
+	assert.Equal(t, Interval{Microseconds: -us}, row[0].Value)
+	assert.Equal(t, "-3000000:00:00", row[0].String())
+
+	value, err := datumFromText(alignments[0], "3000000:00:00")
+	require.NoError(t, err)
+	assert.Equal(t, Interval{Microseconds: us}, value)
+}
+
+func TestParseDateStyle(t *testing.T) {
+	style, order, err := ParseDateStyle("SQL, DMY")
+	require.NoError(t, err)
+	assert.Equal(t, DateStyleSQL, style)
+	assert.Equal(t, DateOrderDMY, order)
+
+	style, order, err = ParseDateStyle("German")
+	require.NoError(t, err)
+	assert.Equal(t, DateStyleGerman, style)
+	assert.Equal(t, DateOrderDMY, order)
+
+	_, _, err = ParseDateStyle("ISO, XYZ")
+	assert.Error(t, err)
+}
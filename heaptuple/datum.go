@@ -0,0 +1,131 @@
+package heaptuple
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"unsafe"
+)
+
+// Datum is the value of one attribute of a tuple.
+type Datum struct {
+	Name string
+	// Type is the typname of the attribute.
+	Type   string
+	IsNull bool
+	// Value is the decoded value, its Go type follows Type: uint32 for oid,
+	// the reg* aliases, xid and cid, uint64 for xid8, int16, int32, int64,
+	// float32, float64, bool, byte for "char", ItemPointer for tid,
+	// XLogRecPtr for pg_lsn, Numeric for numeric, string for name and text,
+	// []byte for bytea and arrays, time.Time for date, timestamp and
+	// timestamptz, time.Duration for time, TimeTZ, Interval, and Infinity
+	// for infinite dates, timestamps and intervals. It is nil for NULL and
+	// for a toast pointer not fetched yet.
+	Value interface{}
+	// Raw is the attribute as stored in the tuple, varlena header included.
+	Raw []byte
+	// Toast is the tag of an external varlena, VARTAG_UNUSED when the
+	// value is inline.
+	Toast EXTERNAL
+	// Compressed tells that the value was stored compressed, inline or in the
+	// toast relation.
+	Compressed bool
+	// Missing tells that the tuple predates the attribute, the value is its
+	// attmissingval.
+	Missing bool
+}
+
+// String returns the value in text form, NULL for a null, dates and times
+// shown with DefaultDateTimeFormat.
+func (d Datum) String() string {
+	return d.Format(DefaultDateTimeFormat)
+}
+
+func (d Datum) text() string {
+	switch v := d.Value.(type) {
+	case nil:
+		if d.Toast != VARTAG_UNUSED {
+			return d.toastPointer()
+		}
+		return string(d.Raw)
+	case uint32, uint64, int16, int32, int64:
+		return fmt.Sprintf("%d", v)
+	case float32:
+		return formatFloat(float64(v), 32)
+	case float64:
+		return formatFloat(v, 64)
+	case bool:
+		if v {
+			return "t"
+		}
+		return "f"
+	case byte:
+		return formatChar(v)
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case fmt.Stringer:
+		return v.String()
+	}
+	return fmt.Sprintf("%v", d.Value)
+}
+
+// toastPointer stands for a toasted value that was not fetched.
+func (d Datum) toastPointer() string {
+	if d.Toast != VARTAG_ONDISK || len(d.Raw) < 2+int(unsafe.Sizeof(ExternalOnDisk{})) {
+		return fmt.Sprintf("<toast pointer, tag %d>", d.Toast)
+	}
+	pointer := d.Raw[2:]
+	return fmt.Sprintf("<toast pointer %d>", (**(**ExternalOnDisk)(unsafe.Pointer(&pointer))).ValueOID)
+}
+
+// Row is a tuple as an ordered list of datums, dropped attributes left out.
+type Row []Datum
+
+// Get returns the datum of the attribute named name.
+func (r Row) Get(name string) (Datum, bool) {
+	for _, d := range r {
+		if d.Name == name {
+			return d, true
+		}
+	}
+	return Datum{}, false
+}
+
+// Names returns the names of the attributes, in order.
+func (r Row) Names() []string {
+	ret := make([]string, len(r))
+	for idx, d := range r {
+		ret[idx] = d.Name
+	}
+	return ret
+}
+
+// Strings returns the text form of every datum keyed by attribute name.
+func (r Row) Strings() map[string]string {
+	return r.Format(DefaultDateTimeFormat)
+}
+
+// datumFromText parses the text form of a value of type item, as found in
+// attmissingval, into the same Go type the decoders return for it.
+func datumFromText(item AttrAlign, text string) (interface{}, error) {
+	if value, ok, err := parseScalar(item.TypName, text); ok {
+		return value, err
+	}
+	if value, ok, err := parseDateTime(item.TypName, text); ok {
+		return value, err
+	}
+	switch item.TypName {
+	case "numeric":
+		return parseNumeric(text)
+	case "name", "text", "cstring":
+		return text, nil
+	case "bytea":
+		if strings.HasPrefix(text, `\x`) {
+			return hex.DecodeString(text[2:])
+		}
+		return []byte(text), nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnsupportedType, item.TypName)
+}
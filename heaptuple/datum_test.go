@@ -0,0 +1,63 @@
+package heaptuple
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rowStrings returns the text form of rows, for comparisons.
+func rowStrings(rows []Row) []map[string]string {
+	var ret []map[string]string
+	for _, row := range rows {
+		ret = append(ret, row.Strings())
+	}
+	return ret
+}
+
+func TestTypedRow(t *testing.T) {
+	alignments := []AttrAlign{
+		{AttName: "id", TypName: "int4", TypAlign: "i", TypLen: 4},
+		{AttName: "gone", TypName: "", TypAlign: "s", TypLen: 2, IsDropped: true},
+		{AttName: "flag", TypName: "bool", TypAlign: "c", TypLen: 1},
+		{AttName: "body", TypName: "text", TypAlign: "i", TypLen: -1},
+		{AttName: "note", TypName: "text", TypAlign: "i", TypLen: -1},
+		{AttName: "small", TypName: "int2", TypAlign: "s", TypLen: 2, HasMissing: true, MissingVal: "7"},
+	}
+	th := TupleHeader{Infomask: HEAP_HASNULL, Infomask2: 5, NullBits: []byte{1, 1, 1, 1, 0}}
+	data := []byte{
+		0x2A, 0, 0, 0, // id
+		0xFF, 0xFF, // gone
+		1, 0, // flag, padding
+		0x07, 'h', 'i', // body
+	}
+	row, err := ParseTupleData(alignments, &th, data)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"id", "flag", "body", "note", "small"}, row.Names())
+	assert.Equal(t, int32(42), row[0].Value)
+	assert.Equal(t, true, row[1].Value)
+	assert.Equal(t, "hi", row[2].Value)
+	assert.Equal(t, []byte{0x07, 'h', 'i'}, row[2].Raw)
+	assert.False(t, row[2].Compressed)
+	assert.True(t, row[3].IsNull)
+	assert.Nil(t, row[3].Value)
+	assert.Equal(t, int16(7), row[4].Value)
+	assert.True(t, row[4].Missing)
+
+	note, ok := row.Get("note")
+	require.True(t, ok)
+	assert.Equal(t, "NULL", note.String())
+	_, ok = row.Get("gone")
+	assert.False(t, ok)
+	assert.Equal(t, map[string]string{"id": "42", "flag": "t", "body": "hi", "note": "NULL", "small": "7"}, row.Strings())
+}
+
+func TestUnfetchedToastPointer(t *testing.T) {
+	alignments := []AttrAlign{{AttName: "body", TypName: "text", TypAlign: "i", TypLen: -1}}
+	row, err := ParseTupleData(alignments, &TupleHeader{Infomask2: 1}, toastPointer(900, 11))
+	require.NoError(t, err)
+	assert.Nil(t, row[0].Value)
+	assert.Equal(t, "<toast pointer 900>", row[0].String())
+}
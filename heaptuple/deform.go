@@ -0,0 +1,211 @@
+package heaptuple
+
+import (
+	"bytes"
+	"fmt"
+)
+
+var typAlignBytes = map[string]int{
+	"c": 1,
+	"s": 2,
+	"i": 4,
+	"d": 8,
+}
+
+// alignOffset is att_align_nominal, it rounds offset up to typAlign, which
+// ParseTupleData has checked to be known.
+func alignOffset(offset int, typAlign string) int {
+	n, ok := typAlignBytes[typAlign]
+	if !ok {
+		return offset
+	}
+	for offset%n != 0 {
+		offset++
+	}
+	return offset
+}
+
+// alignPointer is att_align_pointer for a varlena at offset: a short varlena
+// is not aligned, its header byte is never zero while padding always is.
+func alignPointer(offset int, typAlign string, bins []byte) int {
+	if offset < len(bins) && bins[offset] != 0 {
+		return offset
+	}
+	return alignOffset(offset, typAlign)
+}
+
+// attCacheOffsets computes the attcacheoff of every attribute, the offset
+// known from the descriptor alone as long as no varlena, cstring or NULL
+// comes first. It is -1 when the offset depends on the tuple. The result is
+// kept apart so that the descriptor is never modified.
+func attCacheOffsets(alignments []AttrAlign) []int {
+	ret := make([]int, len(alignments))
+	offset := 0
+	for idx, item := range alignments {
+		ret[idx] = -1
+		if offset < 0 {
+			continue
+		}
+		aligned := alignOffset(offset, item.TypAlign)
+		// a varlena is cached only where no padding can precede it
+		if item.TypLen != -1 || aligned == offset {
+			ret[idx] = aligned
+		}
+		if item.TypLen <= 0 {
+			offset = -1
+			continue
+		}
+		offset = aligned + item.TypLen
+	}
+	return ret
+}
+
+// attLength is att_addlength_pointer, the number of bytes of the attribute at
+// the start of bins.
+func attLength(item AttrAlign, bins []byte) (int, Varlena, error) {
+	switch item.TypLen {
+	case -1:
+		value, err := ParseVarlena(bins)
+		if err != nil {
+			return 0, nil, err
+		}
+		return value.GetLength(), value, nil
+	case -2:
+		end := bytes.IndexByte(bins, 0)
+		if end < 0 {
+			return 0, nil, fmt.Errorf("%w: unterminated cstring", ErrCorruptTuple)
+		}
+		return end + 1, nil, nil
+	}
+	if item.TypLen <= 0 {
+		return 0, nil, fmt.Errorf("%w: typlen %d", ErrUnsupportedType, item.TypLen)
+	}
+	if item.TypLen > len(bins) {
+		return 0, nil, fmt.Errorf("%w: attribute of %d bytes, %d left", ErrCorruptTuple, item.TypLen, len(bins))
+	}
+	return item.TypLen, nil, nil
+}
+
+// ParseTupleData deforms the data area bins of a tuple the way
+// heap_deform_tuple does. Failures are *CorruptionError values whose Offset
+// is relative to bins.
+func ParseTupleData(alignments []AttrAlign, th *TupleHeader, bins []byte) (Row, error) {
+	for _, item := range alignments {
+		if _, ok := typAlignBytes[item.TypAlign]; !ok {
+			return nil, newCorruptionError(fmt.Errorf("%w: unknown alignment %q", ErrUnsupportedType, item.TypAlign), item.AttName, -1)
+		}
+		// fetch_att only reads these widths by value
+		if item.TypByVal && item.TypLen != 1 && item.TypLen != 2 && item.TypLen != 4 && item.TypLen != 8 {
+			return nil, newCorruptionError(fmt.Errorf("%w: by-value type of %d bytes", ErrUnsupportedType, item.TypLen), item.AttName, -1)
+		}
+	}
+
+	// the datums keep pieces of bins, pages are read into a reused buffer
+	bins = append([]byte(nil), bins...)
+
+	var (
+		row      Row
+		offset   int
+		err      error
+		cacheOff = attCacheOffsets(alignments)
+		// set once an offset depends on the tuple rather than the descriptor
+		slow bool
+	)
+	// alignments may describe only a prefix of the attributes, e.g. the
+	// bootstrap descriptors of the system catalogs
+	for i := 0; i < int(th.AttrCnt()) && i < len(alignments); i++ {
+		item := alignments[i]
+		if th.HasNullBits() && th.NullBits[i] == 0 {
+			if !item.IsDropped {
+				row = append(row, Datum{Name: item.AttName, Type: item.TypName, IsNull: true})
+			}
+			slow = true
+			continue
+		}
+
+		switch {
+		case !slow && cacheOff[i] >= 0:
+			offset = cacheOff[i]
+		case item.TypLen == -1:
+			offset = alignPointer(offset, item.TypAlign, bins)
+		default:
+			offset = alignOffset(offset, item.TypAlign)
+		}
+		if offset > len(bins) {
+			return nil, newCorruptionError(fmt.Errorf("%w: attribute beyond the end of the tuple", ErrCorruptTuple), item.AttName, offset)
+		}
+		length, value, err := attLength(item, bins[offset:])
+		if err != nil {
+			return nil, newCorruptionError(err, item.AttName, offset)
+		}
+		// the type of a dropped attribute is gone, only its length is known,
+		// it is skipped without decoding
+		if !item.IsDropped {
+			d, err := decodeAttr(item, value, bins[offset:offset+length])
+			if err != nil {
+				return nil, newCorruptionError(err, item.AttName, offset)
+			}
+			row = append(row, d)
+		}
+		offset += length
+		if item.TypLen <= 0 {
+			slow = true
+		}
+	}
+	// attributes added after the tuple was written take their
+	// attmissingval, like getmissingattr
+	for i := int(th.AttrCnt()); i < len(alignments); i++ {
+		item := alignments[i]
+		if item.IsDropped {
+			continue
+		}
+		d := Datum{Name: item.AttName, Type: item.TypName, IsNull: !item.HasMissing, Missing: item.HasMissing}
+		if item.HasMissing {
+			if d.Value, err = datumFromText(item, item.MissingVal); err != nil {
+				return nil, newCorruptionError(fmt.Errorf("%w: attmissingval %q: %v", ErrCorruptTuple, item.MissingVal, err), item.AttName, -1)
+			}
+		}
+		row = append(row, d)
+	}
+	return row, nil
+}
+
+// decodeAttr decodes the attribute stored in raw, value is its varlena.
+func decodeAttr(item AttrAlign, value Varlena, raw []byte) (Datum, error) {
+	d := Datum{Name: item.AttName, Type: item.TypName, Raw: raw}
+	var err error
+	switch {
+	case value == nil:
+		d.Value, err = decodeFixed(item, raw)
+	case value.GetType() != VARTAG_UNUSED:
+		// fetched from the toast relation by the table
+		d.Toast = value.GetType()
+		if pointer, ok := value.(VarAttrib1BE); ok {
+			d.Compressed = pointer.IsCompressed()
+		}
+	default:
+		if v4b, ok := value.(VarAttrib4B); ok {
+			d.Compressed = v4b.IsCompressed()
+		}
+		var data []byte
+		if data, err = value.GetData(); err == nil {
+			d.Value, err = decodeVarlena(item, data)
+		}
+	}
+	return d, err
+}
+
+// decodeVarlena decodes the data of an inline varlena attribute.
+func decodeVarlena(item AttrAlign, data []byte) (interface{}, error) {
+	switch item.TypName {
+	case "text":
+		return string(data), nil
+	case "numeric":
+		return decodeNumeric(data)
+	case "bytea",
+		// arrays of the catalogs are kept in their on-disk form
+		"anyarray", "_aclitem", "_text":
+		return data, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnsupportedType, item.TypName)
+}
@@ -0,0 +1,72 @@
+package heaptuple
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrCorruptPage     = errors.New("corrupt page")
+	ErrCorruptTuple    = errors.New("corrupt tuple")
+	ErrUnsupportedType = errors.New("unsupported type")
+	ErrDecompress      = errors.New("decompress failed")
+)
+
+// InvalidBlockNumber marks a CorruptionError raised before the block of the
+// page was known, e.g. by ReadPage on bytes of unknown origin.
+const InvalidBlockNumber uint32 = 0xFFFFFFFF
+
+// CorruptionError locates a parse failure. Err wraps one of ErrCorruptPage,
+// ErrCorruptTuple, ErrUnsupportedType or ErrDecompress, test it with
+// errors.Is.
+type CorruptionError struct {
+	Path  string
+	Block uint32
+	// LinePointer is the 1-based line pointer of the tuple, 0 when the page
+	// itself is damaged.
+	LinePointer uint16
+	Attribute   string
+	// Offset is the byte offset inside the page, -1 if unknown.
+	Offset int
+	Err    error
+}
+
+func newCorruptionError(err error, attribute string, offset int) *CorruptionError {
+	return &CorruptionError{Block: InvalidBlockNumber, Attribute: attribute, Offset: offset, Err: err}
+}
+
+func (e *CorruptionError) Error() string {
+	var location []string
+	if e.Path != "" {
+		location = append(location, e.Path)
+	}
+	if e.Block != InvalidBlockNumber {
+		location = append(location, fmt.Sprintf("block %d", e.Block))
+	}
+	if e.LinePointer != 0 {
+		location = append(location, fmt.Sprintf("lp %d", e.LinePointer))
+	}
+	if e.Attribute != "" {
+		location = append(location, fmt.Sprintf("attribute %q", e.Attribute))
+	}
+	if e.Offset >= 0 {
+		location = append(location, fmt.Sprintf("offset %d", e.Offset))
+	}
+	if len(location) == 0 {
+		return e.Err.Error()
+	}
+	return strings.Join(location, " ") + ": " + e.Err.Error()
+}
+
+func (e *CorruptionError) Unwrap() error {
+	return e.Err
+}
+
+// locate fills in where err happened if it is a *CorruptionError.
+func locate(err error, path string, blkno uint32) {
+	var ce *CorruptionError
+	if errors.As(err, &ce) {
+		ce.Path, ce.Block = path, blkno
+	}
+}
@@ -2,38 +2,61 @@ package heaptuple
 
 import (
 	"os"
+	"path/filepath"
+	"strconv"
 )
 
 type HeapFile struct {
 	Pages []Page
+	// Damage lists what was skipped when read with the Salvage option.
+	Damage []DamagedItem
 }
 
+// ReadHeapFile decodes every page of the file at path at once. Large files
+// should be walked with a PageReader instead. When the file ends with a
+// partial block, the pages before it are returned along with a
+// *TruncatedPageError.
 func ReadHeapFile(path string, pageSize int, alignments []AttrAlign) (hf HeapFile, err error) {
-	bytes, err := os.ReadFile(path)
+	return ReadHeapFileWithOptions(path, pageSize, alignments, ReadOptions{})
+}
+
+// ReadHeapFileWithOptions is ReadHeapFile decoding pages with opts. The
+// segment number, needed by checksums, is taken from the .N suffix of path.
+func ReadHeapFileWithOptions(path string, pageSize int, alignments []AttrAlign, opts ReadOptions) (hf HeapFile, err error) {
+	f, err := os.Open(path)
 	if err != nil {
 		return
 	}
-
-	size := len(bytes)
-	pageOffset := []int{0}
-	pageLastIdx := 0
-	for pageOffset[pageLastIdx]+pageSize < size {
-		pageLastIdx++
-		pageOffset = append(pageOffset, pageLastIdx*pageSize)
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return
 	}
-	pageLastIdx++
-	pageOffset = append(pageOffset, size)
 
-	var pages []Page
-	for idx := range pageOffset[:pageLastIdx] {
-		start, end := pageOffset[idx], pageOffset[idx+1]
-		p, err := ReadPage(bytes[start:end], alignments)
-		if err != nil {
-			return hf, err
-		}
-		pages = append(pages, p)
+	pr := NewPageReader(f, info.Size(), pageSize, alignments)
+	pr.SetOptions(opts)
+	pr.SetSegment(segmentOf(path, pageSize))
+	for pr.Next() {
+		hf.Pages = append(hf.Pages, pr.Page())
 	}
-	hf.Pages = pages
-
+	hf.Damage = pr.Damage()
+	err = pr.Err()
 	return
 }
+
+// segmentOf guesses the segment of a relation file from its name,
+// <relfilenode>[_fork][.segno].
+func segmentOf(path string, pageSize int) Segment {
+	seg := Segment{Path: path}
+	ext := filepath.Ext(path)
+	if ext == "" {
+		return seg
+	}
+	segNo, err := strconv.Atoi(ext[1:])
+	if err != nil || segNo <= 0 {
+		return seg
+	}
+	seg.SegNo = segNo
+	seg.FirstBlock = uint32(segNo) * uint32(RelSegBytes/pageSize)
+	return seg
+}
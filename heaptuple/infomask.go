@@ -0,0 +1,182 @@
+package heaptuple
+
+// t_infomask, see access/htup_details.h
+const (
+	HEAP_HASNULL          = 0x0001 // has null attribute(s)
+	HEAP_HASVARWIDTH      = 0x0002 // has variable-width attribute(s)
+	HEAP_HASEXTERNAL      = 0x0004 // has external stored attribute(s)
+	HEAP_HASOID_OLD       = 0x0008 // has an object-id field, before PostgreSQL 12
+	HEAP_XMAX_KEYSHR_LOCK = 0x0010 // xmax is a key-shared locker
+	HEAP_COMBOCID         = 0x0020 // t_cid is a combo CID
+	HEAP_XMAX_EXCL_LOCK   = 0x0040 // xmax is exclusive locker
+	HEAP_XMAX_LOCK_ONLY   = 0x0080 // xmax, if valid, is only a locker
+
+	HEAP_XMAX_SHR_LOCK = HEAP_XMAX_EXCL_LOCK | HEAP_XMAX_KEYSHR_LOCK
+	HEAP_LOCK_MASK     = HEAP_XMAX_SHR_LOCK | HEAP_XMAX_EXCL_LOCK | HEAP_XMAX_KEYSHR_LOCK
+
+	HEAP_XMIN_COMMITTED = 0x0100 // t_xmin committed
+	HEAP_XMIN_INVALID   = 0x0200 // t_xmin invalid/aborted
+	HEAP_XMIN_FROZEN    = HEAP_XMIN_COMMITTED | HEAP_XMIN_INVALID
+	HEAP_XMAX_COMMITTED = 0x0400 // t_xmax committed
+	HEAP_XMAX_INVALID   = 0x0800 // t_xmax invalid/aborted
+	HEAP_XMAX_IS_MULTI  = 0x1000 // t_xmax is a MultiXactId
+	HEAP_UPDATED        = 0x2000 // this is UPDATEd version of row
+	HEAP_MOVED_OFF      = 0x4000 // moved to another place by pre-9.0 VACUUM FULL
+	HEAP_MOVED_IN       = 0x8000 // moved from another place by pre-9.0 VACUUM FULL
+	HEAP_MOVED          = HEAP_MOVED_OFF | HEAP_MOVED_IN
+)
+
+// t_infomask2
+const (
+	HEAP_NATTS_MASK   = 0x07FF // 11 bits for number of attributes
+	HEAP_KEYS_UPDATED = 0x2000 // tuple was updated and key cols modified, or tuple deleted
+	HEAP_HOT_UPDATED  = 0x4000 // tuple was HOT-updated
+	HEAP_ONLY_TUPLE   = 0x8000 // this is heap-only tuple
+)
+
+func (th TupleHeader) HasVarWidth() bool {
+	return th.Infomask&HEAP_HASVARWIDTH != 0
+}
+
+func (th TupleHeader) HasExternal() bool {
+	return th.Infomask&HEAP_HASEXTERNAL != 0
+}
+
+func (th TupleHeader) HasOidOld() bool {
+	return th.Infomask&HEAP_HASOID_OLD != 0
+}
+
+func (th TupleHeader) IsComboCid() bool {
+	return th.Infomask&HEAP_COMBOCID != 0
+}
+
+// XminCommitted is HeapTupleHeaderXminCommitted, also true for frozen
+// tuples.
+func (th TupleHeader) XminCommitted() bool {
+	return th.Infomask&HEAP_XMIN_COMMITTED != 0
+}
+
+// XminInvalid is HeapTupleHeaderXminInvalid, false for frozen tuples.
+func (th TupleHeader) XminInvalid() bool {
+	return th.Infomask&HEAP_XMIN_FROZEN == HEAP_XMIN_INVALID
+}
+
+func (th TupleHeader) XminFrozen() bool {
+	return th.Infomask&HEAP_XMIN_FROZEN == HEAP_XMIN_FROZEN
+}
+
+func (th TupleHeader) XmaxCommitted() bool {
+	return th.Infomask&HEAP_XMAX_COMMITTED != 0
+}
+
+func (th TupleHeader) XmaxInvalid() bool {
+	return th.Infomask&HEAP_XMAX_INVALID != 0
+}
+
+func (th TupleHeader) XmaxIsMulti() bool {
+	return th.Infomask&HEAP_XMAX_IS_MULTI != 0
+}
+
+// XmaxIsLockedOnly is HEAP_XMAX_IS_LOCKED_ONLY, it also recognizes the
+// lockers of pre-9.3 that only set HEAP_XMAX_EXCL_LOCK.
+func (th TupleHeader) XmaxIsLockedOnly() bool {
+	return th.Infomask&HEAP_XMAX_LOCK_ONLY != 0 ||
+		th.Infomask&(HEAP_XMAX_IS_MULTI|HEAP_LOCK_MASK) == HEAP_XMAX_EXCL_LOCK
+}
+
+func (th TupleHeader) XmaxIsKeyShrLocked() bool {
+	return th.Infomask&HEAP_LOCK_MASK == HEAP_XMAX_KEYSHR_LOCK
+}
+
+func (th TupleHeader) XmaxIsShrLocked() bool {
+	return th.Infomask&HEAP_LOCK_MASK == HEAP_XMAX_SHR_LOCK
+}
+
+func (th TupleHeader) XmaxIsExclLocked() bool {
+	return th.Infomask&HEAP_LOCK_MASK == HEAP_XMAX_EXCL_LOCK
+}
+
+func (th TupleHeader) IsUpdated() bool {
+	return th.Infomask&HEAP_UPDATED != 0
+}
+
+func (th TupleHeader) IsMovedOff() bool {
+	return th.Infomask&HEAP_MOVED_OFF != 0
+}
+
+func (th TupleHeader) IsMovedIn() bool {
+	return th.Infomask&HEAP_MOVED_IN != 0
+}
+
+func (th TupleHeader) KeysUpdated() bool {
+	return th.Infomask2&HEAP_KEYS_UPDATED != 0
+}
+
+func (th TupleHeader) IsHotUpdated() bool {
+	return th.Infomask2&HEAP_HOT_UPDATED != 0
+}
+
+func (th TupleHeader) IsHeapOnly() bool {
+	return th.Infomask2&HEAP_ONLY_TUPLE != 0
+}
+
+var (
+	infomaskFlagNames = []struct {
+		mask uint16
+		name string
+	}{
+		{HEAP_HASNULL, "HEAP_HASNULL"},
+		{HEAP_HASVARWIDTH, "HEAP_HASVARWIDTH"},
+		{HEAP_HASEXTERNAL, "HEAP_HASEXTERNAL"},
+		{HEAP_HASOID_OLD, "HEAP_HASOID_OLD"},
+		{HEAP_XMAX_KEYSHR_LOCK, "HEAP_XMAX_KEYSHR_LOCK"},
+		{HEAP_COMBOCID, "HEAP_COMBOCID"},
+		{HEAP_XMAX_EXCL_LOCK, "HEAP_XMAX_EXCL_LOCK"},
+		{HEAP_XMAX_LOCK_ONLY, "HEAP_XMAX_LOCK_ONLY"},
+		{HEAP_XMIN_COMMITTED, "HEAP_XMIN_COMMITTED"},
+		{HEAP_XMIN_INVALID, "HEAP_XMIN_INVALID"},
+		{HEAP_XMAX_COMMITTED, "HEAP_XMAX_COMMITTED"},
+		{HEAP_XMAX_INVALID, "HEAP_XMAX_INVALID"},
+		{HEAP_XMAX_IS_MULTI, "HEAP_XMAX_IS_MULTI"},
+		{HEAP_UPDATED, "HEAP_UPDATED"},
+		{HEAP_MOVED_OFF, "HEAP_MOVED_OFF"},
+		{HEAP_MOVED_IN, "HEAP_MOVED_IN"},
+	}
+	infomask2FlagNames = []struct {
+		mask uint16
+		name string
+	}{
+		{HEAP_KEYS_UPDATED, "HEAP_KEYS_UPDATED"},
+		{HEAP_HOT_UPDATED, "HEAP_HOT_UPDATED"},
+		{HEAP_ONLY_TUPLE, "HEAP_ONLY_TUPLE"},
+	}
+)
+
+// InfomaskFlags is heap_tuple_infomask_flags of pageinspect: raw lists every
+// bit set in t_infomask and t_infomask2, combined the flags made of several
+// bits, HEAP_XMAX_SHR_LOCK, HEAP_XMIN_FROZEN and HEAP_MOVED.
+func (th TupleHeader) InfomaskFlags() (raw, combined []string) {
+	raw = []string{}
+	for _, flag := range infomaskFlagNames {
+		if th.Infomask&flag.mask != 0 {
+			raw = append(raw, flag.name)
+		}
+	}
+	for _, flag := range infomask2FlagNames {
+		if th.Infomask2&flag.mask != 0 {
+			raw = append(raw, flag.name)
+		}
+	}
+
+	combined = []string{}
+	if th.Infomask&HEAP_XMAX_SHR_LOCK == HEAP_XMAX_SHR_LOCK {
+		combined = append(combined, "HEAP_XMAX_SHR_LOCK")
+	}
+	if th.Infomask&HEAP_XMIN_FROZEN == HEAP_XMIN_FROZEN {
+		combined = append(combined, "HEAP_XMIN_FROZEN")
+	}
+	if th.Infomask&HEAP_MOVED == HEAP_MOVED {
+		combined = append(combined, "HEAP_MOVED")
+	}
+	return raw, combined
+}
@@ -0,0 +1,122 @@
+package heaptuple
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// ItemPointer is ItemPointerData, the location of a tuple: a block and the
+// 1-based line pointer inside it.
+type ItemPointer struct {
+	Block  uint32
+	Offset uint16
+}
+
+// ParseItemPointer decodes the 6 bytes of an ItemPointerData, the block
+// number is stored as two 16 bits halves, high first.
+func ParseItemPointer(bins [6]byte) ItemPointer {
+	hi := binary.LittleEndian.Uint16(bins[0:2])
+	lo := binary.LittleEndian.Uint16(bins[2:4])
+	return ItemPointer{
+		Block:  uint32(hi)<<16 | uint32(lo),
+		Offset: binary.LittleEndian.Uint16(bins[4:6]),
+	}
+}
+
+func (ip ItemPointer) String() string {
+	return fmt.Sprintf("(%d,%d)", ip.Block, ip.Offset)
+}
+
+// movedPartitionsOffset is the t_ctid offset of a tuple moved to another
+// partition by an UPDATE, the chain continues in another relation.
+const movedPartitionsOffset = 0xFFFD
+
+// GetCtid returns t_ctid, the tuple itself or its newer version.
+func (th TupleHeader) GetCtid() ItemPointer {
+	return ParseItemPointer(th.Ctid)
+}
+
+// TupleVersion is one version of a row on its update chain.
+type TupleVersion struct {
+	Ctid ItemPointer
+	Xmin uint32
+	Xmax uint32
+	// XmaxInfo is the DescribeXmax of the version with the Visibility of the
+	// table.
+	XmaxInfo string
+	Header   TupleHeader
+	Data     Row
+}
+
+// VersionChain follows the update chain of the row from the version at
+// start to the newest one, like heap_get_latest_tid. Redirect line pointers
+// of HOT chains are followed, the chain ends at a pruned item or a tuple that
+// was not updated.
+func (t Table) VersionChain(start ItemPointer) ([]TupleVersion, error) {
+	var (
+		ret       []TupleVersion
+		priorXmax uint32
+		checkXmin bool
+		cur       = start
+		pages     = make(map[uint32]Page)
+		visited   = make(map[ItemPointer]bool)
+		toast     = t.newToastReader()
+	)
+	for !visited[cur] {
+		visited[cur] = true
+		page, ok := pages[cur.Block]
+		if !ok {
+			bytes, err := t.self.ReadBlock(MainForkNum, cur.Block)
+			if err != nil {
+				return ret, err
+			}
+			page, err = ReadPage(bytes, t.selfAttrAlign)
+			locate(err, t.self.Path, cur.Block)
+			if err != nil {
+				return ret, err
+			}
+			pages[cur.Block] = page
+		}
+		if cur.Offset == 0 || int(cur.Offset) > len(page.Slots) {
+			return ret, fmt.Errorf("item pointer %s out of range, %d line pointers", cur, len(page.Slots))
+		}
+
+		slot := page.Slots[cur.Offset-1]
+		switch slot.GetFlags() {
+		case LP_REDIRECT:
+			cur.Offset = slot.GetRedirect()
+			continue
+		case LP_NORMAL:
+		default:
+			// pruned, the rest of the chain is gone
+			return ret, nil
+		}
+		tp := page.Tuples[cur.Offset-1]
+		locate(tp.Err, t.self.Path, cur.Block)
+		if tp.Err != nil {
+			return ret, tp.Err
+		}
+		// the slot was reused by an unrelated tuple, see heap_hot_search_buffer
+		if checkXmin && tp.Header.Xmin != priorXmax {
+			return ret, nil
+		}
+		if err := t.detoast(tp.Data, toast); err != nil {
+			return ret, err
+		}
+		info, err := DescribeXmax(tp.Header, t.vis)
+		if err != nil {
+			return ret, err
+		}
+		ret = append(ret, TupleVersion{Ctid: cur, Xmin: tp.Header.Xmin, Xmax: tp.Header.Xmax, XmaxInfo: info, Header: tp.Header, Data: tp.Data})
+
+		next := tp.Header.GetCtid()
+		if tp.Header.XmaxInvalid() || tp.Header.XmaxIsLockedOnly() || next == cur || next.Offset == movedPartitionsOffset {
+			return ret, nil
+		}
+		// the xmax of a multixact is not the updater, the check against the
+		// next xmin is skipped
+		priorXmax, checkXmin = tp.Header.Xmax, !tp.Header.XmaxIsMulti()
+		cur = next
+	}
+	return ret, nil
+}
@@ -0,0 +1,74 @@
+package heaptuple
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseItemPointer(t *testing.T) {
+	ip := ParseItemPointer([6]byte{0x01, 0x00, 0x02, 0x00, 0x03, 0x00})
+	assert.Equal(t, ItemPointer{Block: 65538, Offset: 3}, ip)
+	assert.Equal(t, "(65538,3)", ip.String())
+}
+
+func TestVersionChain(t *testing.T) {
+	alignments := []AttrAlign{{AttName: "v", TypName: "int4", TypAlign: "i", TypLen: 4}}
+	version := func(v int32, xmin, xmax uint32, ctid ItemPointer, infomask2 uint16) []byte {
+		return buildTuple(testTuple{xmin: xmin, xmax: xmax, ctid: ctid, infomask2: infomask2,
+			nulls: []bool{false}, data: encodeAttrs(alignments, v)})
+	}
+	// (0,1) was pruned into a redirect to the HOT chain (0,2) -> (0,3), the
+	// last update left the page
+	first := buildPage(DefaultBlockSize,
+		version(1, 9, 10, ItemPointer{0, 2}, HEAP_HOT_UPDATED),
+		version(2, 10, 11, ItemPointer{0, 3}, HEAP_HOT_UPDATED|HEAP_ONLY_TUPLE),
+		version(3, 11, 12, ItemPointer{1, 1}, HEAP_ONLY_TUPLE),
+		version(9, 20, 0, ItemPointer{0, 4}, 0),
+	)
+	setSlot(first, 0, LP_REDIRECT, 2, 0)
+	second := buildPage(DefaultBlockSize, version(4, 12, 0, ItemPointer{1, 1}, 0))
+	path := filepath.Join(t.TempDir(), "16384")
+	require.NoError(t, os.WriteFile(path, append(first, second...), 0o644))
+
+	catalog := NewMemCatalog(DefaultBlockSize)
+	catalog.Add("t", MemRelation{Path: path, AttrAligns: alignments})
+	table, err := NewTable(context.Background(), catalog, "t")
+	require.NoError(t, err)
+
+	chain, err := table.VersionChain(ItemPointer{0, 1})
+	require.NoError(t, err)
+	require.Len(t, chain, 3)
+	for idx, want := range []struct {
+		ctid       ItemPointer
+		xmin, xmax uint32
+		v          string
+	}{
+		{ItemPointer{0, 2}, 10, 11, "2"},
+		{ItemPointer{0, 3}, 11, 12, "3"},
+		{ItemPointer{1, 1}, 12, 0, "4"},
+	} {
+		assert.Equal(t, want.ctid, chain[idx].Ctid)
+		assert.Equal(t, want.xmin, chain[idx].Xmin)
+		assert.Equal(t, want.xmax, chain[idx].Xmax)
+		assert.Equal(t, want.v, chain[idx].Data.Strings()["v"])
+	}
+
+	chain, err = table.VersionChain(ItemPointer{0, 4})
+	require.NoError(t, err)
+	require.Len(t, chain, 1)
+
+	_, err = table.VersionChain(ItemPointer{0, 9})
+	assert.Error(t, err)
+
+	// the successor was vacuumed away and its slot reused
+	second = buildPage(DefaultBlockSize, version(5, 30, 0, ItemPointer{1, 1}, 0))
+	require.NoError(t, os.WriteFile(path, append(first, second...), 0o644))
+	chain, err = table.VersionChain(ItemPointer{0, 1})
+	require.NoError(t, err)
+	assert.Len(t, chain, 2)
+}
@@ -56,11 +56,15 @@ package heaptuple
 //
 // 				/*
 // 				 * Check for corrupt data: if we fell off the end of the
-// 				 * source, or if we obtained off = 0, we have problems.  (We
-// 				 * must check this, else we risk an infinite loop below in the
-// 				 * face of corrupt data.)
+// 				 * source, or if we obtained off = 0, or if off is more than
+// 				 * the distance back to the buffer start, we have problems.
+// 				 * (We must check for off = 0, else we risk an infinite loop
+// 				 * below in the face of corrupt data.  Likewise, the upper
+// 				 * limit on off prevents accessing outside the buffer
+// 				 * boundaries.)
 // 				 */
-// 				if (unlikely(sp > srcend || off == 0))
+// 				if (unlikely(sp > srcend || off == 0 ||
+// 							 off > (dp - (unsigned char *) dest)))
 // 					return -1;
 //
 // 				/*
@@ -151,6 +155,9 @@ import (
 )
 
 func Decompress(src []byte, dest []byte) error {
+	if len(src) == 0 || len(dest) == 0 {
+		return fmt.Errorf("%w: %d compressed bytes into %d", ErrDecompress, len(src), len(dest))
+	}
 	srcAddr := (*C.char)(unsafe.Pointer(&src[0]))
 	srcLen := len(src)
 	destAddr := (*C.char)(unsafe.Pointer(&dest[0]))
@@ -158,7 +165,7 @@ func Decompress(src []byte, dest []byte) error {
 
 	size := C.pglz_decompress(srcAddr, C.int32(srcLen), destAddr, C.int32(destLen), C.bool(true))
 	if size == -1 || size != C.int32(len(dest)) {
-		return fmt.Errorf("decompress failed")
+		return fmt.Errorf("%w: pglz data does not expand to %d bytes", ErrDecompress, len(dest))
 	}
 	return nil
 }
@@ -0,0 +1,254 @@
+package heaptuple
+
+import (
+	"encoding/binary"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// MultiXactId stands in t_xmax for a set of transactions locking or updating
+// the tuple together, when HEAP_XMAX_IS_MULTI is set.
+type MultiXactId uint32
+
+const (
+	InvalidMultiXactId MultiXactId = 0
+	FirstMultiXactId   MultiXactId = 1
+)
+
+// MultiXactStatus is the lock mode of a member.
+type MultiXactStatus uint8
+
+const (
+	MultiXactStatusForKeyShare    MultiXactStatus = 0x00
+	MultiXactStatusForShare       MultiXactStatus = 0x01
+	MultiXactStatusForNoKeyUpdate MultiXactStatus = 0x02
+	MultiXactStatusForUpdate      MultiXactStatus = 0x03
+	// an update that doesn't touch "key" columns
+	MultiXactStatusNoKeyUpdate MultiXactStatus = 0x04
+	// other updates, and delete
+	MultiXactStatusUpdate MultiXactStatus = 0x05
+)
+
+func (s MultiXactStatus) String() string {
+	switch s {
+	case MultiXactStatusForKeyShare:
+		return "FOR KEY SHARE"
+	case MultiXactStatusForShare:
+		return "FOR SHARE"
+	case MultiXactStatusForNoKeyUpdate:
+		return "FOR NO KEY UPDATE"
+	case MultiXactStatusForUpdate:
+		return "FOR UPDATE"
+	case MultiXactStatusNoKeyUpdate:
+		return "NO KEY UPDATE"
+	case MultiXactStatusUpdate:
+		return "UPDATE"
+	}
+	return fmt.Sprintf("MultiXactStatus(%d)", uint8(s))
+}
+
+// IsUpdate is ISUPDATE_from_mxstatus, the member updated or deleted the
+// tuple rather than locking it.
+func (s MultiXactStatus) IsUpdate() bool {
+	return s > MultiXactStatusForUpdate
+}
+
+type MultiXactMember struct {
+	Xid    TransactionId
+	Status MultiXactStatus
+}
+
+// The layout of pg_multixact, see access/transam/multixact.c. The members
+// are stored in groups of 4 xids preceded by one flag byte each.
+const (
+	multiXactOffsetsPerPage       = slruPageSize / 4
+	multiXactMembersPerGroup      = 4
+	multiXactFlagBytesPerGroup    = 4
+	multiXactMemberGroupSize      = 4*multiXactMembersPerGroup + multiXactFlagBytesPerGroup
+	multiXactMemberGroupsPerPage  = slruPageSize / multiXactMemberGroupSize
+	multiXactMembersPerPage       = multiXactMemberGroupsPerPage * multiXactMembersPerGroup
+	multiXactMemberBitsPerXact    = 8
+	maxMultiXactMembersUnassigned = 1 << 16
+)
+
+// MultiXact expands MultiXactIds with pg_multixact/offsets and
+// pg_multixact/members.
+type MultiXact struct {
+	offsets *slru
+	members *slru
+}
+
+// OpenMultiXact reads the pg_multixact directory of the cluster at pgdata.
+func OpenMultiXact(pgdata string) *MultiXact {
+	return &MultiXact{
+		offsets: newSlru(filepath.Join(pgdata, "pg_multixact", "offsets")),
+		members: newSlru(filepath.Join(pgdata, "pg_multixact", "members")),
+	}
+}
+
+func (m *MultiXact) offset(multi MultiXactId) (uint32, error) {
+	page, err := m.offsets.readPage(int64(multi / multiXactOffsetsPerPage))
+	if err != nil {
+		return 0, err
+	}
+	entry := multi % multiXactOffsetsPerPage
+	return binary.LittleEndian.Uint32(page[entry*4:]), nil
+}
+
+func (m *MultiXact) member(offset uint32) (MultiXactMember, error) {
+	page, err := m.members.readPage(int64(offset / multiXactMembersPerPage))
+	if err != nil {
+		return MultiXactMember{}, err
+	}
+	flagsOffset := offset / multiXactMembersPerGroup % multiXactMemberGroupsPerPage * multiXactMemberGroupSize
+	bshift := offset % multiXactMembersPerGroup * multiXactMemberBitsPerXact
+	memberOffset := flagsOffset + multiXactFlagBytesPerGroup + offset%multiXactMembersPerGroup*4
+	flags := binary.LittleEndian.Uint32(page[flagsOffset:])
+	return MultiXactMember{
+		Xid:    TransactionId(binary.LittleEndian.Uint32(page[memberOffset:])),
+		Status: MultiXactStatus(flags >> bshift & 0xFF),
+	}, nil
+}
+
+// Members is GetMultiXactIdMembers. The number of members is told by the
+// offset of the next MultiXactId, for the newest one which has none yet the
+// members are read up to the first unused slot.
+func (m *MultiXact) Members(multi MultiXactId) ([]MultiXactMember, error) {
+	if multi == InvalidMultiXactId {
+		return nil, fmt.Errorf("invalid MultiXactId %d", multi)
+	}
+	offset, err := m.offset(multi)
+	if err != nil {
+		return nil, err
+	}
+	if offset == 0 {
+		return nil, fmt.Errorf("MultiXactId %d has not been created", multi)
+	}
+	next := multi + 1
+	if next < FirstMultiXactId {
+		next = FirstMultiXactId
+	}
+	length := uint32(maxMultiXactMembersUnassigned)
+	nextOffset, err := m.offset(next)
+	if err == nil && nextOffset != 0 {
+		length = nextOffset - offset
+	}
+
+	var ret []MultiXactMember
+	for i := uint32(0); i < length; i++ {
+		member, err := m.member(offset + i)
+		if err != nil && nextOffset == 0 {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if member.Xid == InvalidTransactionId {
+			// the slot 0 skipped at wraparound, or the end of the newest
+			if nextOffset == 0 {
+				break
+			}
+			continue
+		}
+		ret = append(ret, member)
+	}
+	return ret, nil
+}
+
+// UpdateXid is HeapTupleGetUpdateXid, the member that updated or deleted the
+// tuple, InvalidTransactionId if they all only lock it.
+func (m *MultiXact) UpdateXid(multi MultiXactId) (TransactionId, error) {
+	members, err := m.Members(multi)
+	if err != nil {
+		return InvalidTransactionId, err
+	}
+	for _, member := range members {
+		if member.Status.IsUpdate() {
+			return member.Xid, nil
+		}
+	}
+	return InvalidTransactionId, nil
+}
+
+// xmaxLockMode is the lock taken by a plain xmax that only locks, see
+// get_mxact_status_for_lock.
+func xmaxLockMode(th TupleHeader) MultiXactStatus {
+	switch {
+	case th.XmaxIsKeyShrLocked():
+		return MultiXactStatusForKeyShare
+	case th.XmaxIsShrLocked():
+		return MultiXactStatusForShare
+	case th.KeysUpdated():
+		return MultiXactStatusForUpdate
+	}
+	return MultiXactStatusForNoKeyUpdate
+}
+
+// DescribeXmax tells in words who deleted, updated or locked the tuple, like
+// "locked by xids {745,746} in FOR KEY SHARE". It is empty when xmax is
+// unused. With v the updater is followed by its commit status, and a
+// MultiXactId is expanded when v has a MultiXact, otherwise it is shown as
+// is.
+func DescribeXmax(th TupleHeader, v *Visibility) (string, error) {
+	if th.Xmax == 0 || th.XmaxInvalid() {
+		return "", nil
+	}
+	if !th.XmaxIsMulti() {
+		if th.XmaxIsLockedOnly() {
+			return fmt.Sprintf("locked by xid %d in %s", th.Xmax, xmaxLockMode(th)), nil
+		}
+		return v.describeUpdater(TransactionId(th.Xmax))
+	}
+	if v == nil || v.multi == nil {
+		return fmt.Sprintf("multixact %d", th.Xmax), nil
+	}
+	members, err := v.multi.Members(MultiXactId(th.Xmax))
+	if err != nil {
+		return "", err
+	}
+
+	var (
+		parts   []string
+		lockers = make(map[MultiXactStatus][]string)
+	)
+	for _, member := range members {
+		if member.Status.IsUpdate() {
+			part, err := v.describeUpdater(member.Xid, member.Status.String())
+			if err != nil {
+				return "", err
+			}
+			parts = append(parts, part)
+			continue
+		}
+		lockers[member.Status] = append(lockers[member.Status], fmt.Sprintf("%d", member.Xid))
+	}
+	for status := MultiXactStatusForKeyShare; status <= MultiXactStatusForUpdate; status++ {
+		xids := lockers[status]
+		switch len(xids) {
+		case 0:
+		case 1:
+			parts = append(parts, fmt.Sprintf("locked by xid %s in %s", xids[0], status))
+		default:
+			parts = append(parts, fmt.Sprintf("locked by xids {%s} in %s", strings.Join(xids, ","), status))
+		}
+	}
+	return strings.Join(parts, ", "), nil
+}
+
+// describeUpdater is "updated by xid N" followed by notes, and by the status
+// of xid when v is set. A subtransaction has the status of its parent.
+func (v *Visibility) describeUpdater(xid TransactionId, notes ...string) (string, error) {
+	if v != nil {
+		status, err := v.Status(xid)
+		if err != nil {
+			return "", err
+		}
+		notes = append(notes, status.String())
+	}
+	ret := fmt.Sprintf("updated by xid %d", xid)
+	if len(notes) > 0 {
+		ret += " (" + strings.Join(notes, ", ") + ")"
+	}
+	return ret, nil
+}
@@ -0,0 +1,146 @@
+package heaptuple
+
+import (
+	"context"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeMultiXact lays out pg_multixact under pgdata holding multis, created
+// in order from FirstMultiXactId with their members from offset 1.
+func writeMultiXact(t *testing.T, pgdata string, multis ...[]MultiXactMember) {
+	offsetsDir := filepath.Join(pgdata, "pg_multixact", "offsets")
+	membersDir := filepath.Join(pgdata, "pg_multixact", "members")
+	require.NoError(t, os.MkdirAll(offsetsDir, 0o755))
+	require.NoError(t, os.MkdirAll(membersDir, 0o755))
+
+	offsets := make([]byte, slruPageSize)
+	members := make([]byte, slruPageSize)
+	offset := uint32(1)
+	for idx, multi := range multis {
+		binary.LittleEndian.PutUint32(offsets[(idx+1)*4:], offset)
+		for _, member := range multi {
+			flagsOffset := offset / multiXactMembersPerGroup * multiXactMemberGroupSize
+			members[flagsOffset+offset%multiXactMembersPerGroup] = byte(member.Status)
+			binary.LittleEndian.PutUint32(members[flagsOffset+multiXactFlagBytesPerGroup+offset%multiXactMembersPerGroup*4:], uint32(member.Xid))
+			offset++
+		}
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(offsetsDir, "0000"), offsets, 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(membersDir, "0000"), members, 0o644))
+}
+
+func TestMultiXact(t *testing.T) {
+	pgdata := t.TempDir()
+	writeMultiXact(t, pgdata,
+		[]MultiXactMember{{100, MultiXactStatusForKeyShare}, {101, MultiXactStatusForKeyShare}},
+		[]MultiXactMember{{100, MultiXactStatusForKeyShare}, {103, MultiXactStatusUpdate}, {104, MultiXactStatusForShare}},
+		[]MultiXactMember{{105, MultiXactStatusForNoKeyUpdate}},
+	)
+	m := OpenMultiXact(pgdata)
+
+	members, err := m.Members(1)
+	require.NoError(t, err)
+	assert.Equal(t, []MultiXactMember{{100, MultiXactStatusForKeyShare}, {101, MultiXactStatusForKeyShare}}, members)
+	members, err = m.Members(2)
+	require.NoError(t, err)
+	assert.Len(t, members, 3)
+	// the newest, whose end is not recorded
+	members, err = m.Members(3)
+	require.NoError(t, err)
+	assert.Equal(t, []MultiXactMember{{105, MultiXactStatusForNoKeyUpdate}}, members)
+	_, err = m.Members(4)
+	assert.Error(t, err)
+
+	xid, err := m.UpdateXid(1)
+	require.NoError(t, err)
+	assert.Equal(t, InvalidTransactionId, xid)
+	xid, err = m.UpdateXid(2)
+	require.NoError(t, err)
+	assert.EqualValues(t, 103, xid)
+
+	writeClog(t, pgdata, map[TransactionId]XidStatus{
+		7:   TRANSACTION_STATUS_ABORTED,
+		10:  TRANSACTION_STATUS_COMMITTED,
+		103: TRANSACTION_STATUS_COMMITTED,
+	})
+	described := NewVisibility(OpenCLOG(pgdata), nil)
+	described.SetMultiXact(m)
+	for _, c := range []struct {
+		th   TupleHeader
+		want string
+	}{
+		{TupleHeader{Xmax: 1, Infomask: HEAP_XMAX_IS_MULTI | HEAP_XMAX_LOCK_ONLY | HEAP_XMAX_KEYSHR_LOCK}, "locked by xids {100,101} in FOR KEY SHARE"},
+		{TupleHeader{Xmax: 2, Infomask: HEAP_XMAX_IS_MULTI}, "updated by xid 103 (UPDATE, committed), locked by xid 100 in FOR KEY SHARE, locked by xid 104 in FOR SHARE"},
+		{TupleHeader{Xmax: 7, Infomask: HEAP_XMAX_LOCK_ONLY | HEAP_XMAX_EXCL_LOCK, Infomask2: HEAP_KEYS_UPDATED}, "locked by xid 7 in FOR UPDATE"},
+		{TupleHeader{Xmax: 7}, "updated by xid 7 (aborted)"},
+		{TupleHeader{Xmax: 7, Infomask: HEAP_XMAX_INVALID}, ""},
+	} {
+		info, err := DescribeXmax(c.th, described)
+		require.NoError(t, err)
+		assert.Equal(t, c.want, info)
+	}
+	info, err := DescribeXmax(TupleHeader{Xmax: 2, Infomask: HEAP_XMAX_IS_MULTI}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "multixact 2", info)
+	info, err = DescribeXmax(TupleHeader{Xmax: 7}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "updated by xid 7", info)
+
+	v := NewVisibility(OpenCLOG(pgdata), nil)
+	updated := TupleHeader{Xmin: 10, Xmax: 2, Infomask: HEAP_XMAX_IS_MULTI}
+	ok, err := v.TupleVisible(updated)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	v.SetMultiXact(m)
+	ok, err = v.TupleVisible(updated)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	ok, err = v.TupleVisible(TupleHeader{Xmin: 10, Xmax: 1, Infomask: HEAP_XMAX_IS_MULTI})
+	require.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func TestScannerXmaxInfo(t *testing.T) {
+	pgdata := t.TempDir()
+	writeMultiXact(t, pgdata, []MultiXactMember{{100, MultiXactStatusForKeyShare}, {101, MultiXactStatusForKeyShare}})
+	writeClog(t, pgdata, map[TransactionId]XidStatus{10: TRANSACTION_STATUS_COMMITTED})
+
+	alignments := []AttrAlign{{AttName: "v", TypName: "int4", TypAlign: "i", TypLen: 4}}
+	path := filepath.Join(pgdata, "16384")
+	require.NoError(t, os.WriteFile(path, buildPage(DefaultBlockSize,
+		buildTuple(testTuple{xmin: 10, xmax: 1, infomask: HEAP_XMAX_IS_MULTI | HEAP_XMAX_LOCK_ONLY | HEAP_XMAX_KEYSHR_LOCK,
+			nulls: []bool{false}, data: encodeAttrs(alignments, int32(1))}),
+		buildTuple(testTuple{xmin: 10, nulls: []bool{false}, data: encodeAttrs(alignments, int32(2))}),
+	), 0o644))
+	catalog := NewMemCatalog(DefaultBlockSize)
+	catalog.Add("t", MemRelation{Path: path, AttrAligns: alignments})
+	table, err := NewTable(context.Background(), catalog, "t")
+	require.NoError(t, err)
+
+	s := table.Scan()
+	defer s.Close()
+	require.True(t, s.Next())
+	info, err := s.XmaxInfo()
+	require.NoError(t, err)
+	assert.Equal(t, "multixact 1", info)
+
+	v := NewVisibility(OpenCLOG(pgdata), nil)
+	v.SetMultiXact(OpenMultiXact(pgdata))
+	s = table.WithVisibility(v).Scan()
+	defer s.Close()
+	require.True(t, s.Next())
+	info, err = s.XmaxInfo()
+	require.NoError(t, err)
+	assert.Equal(t, "locked by xids {100,101} in FOR KEY SHARE", info)
+	require.True(t, s.Next())
+	info, err = s.XmaxInfo()
+	require.NoError(t, err)
+	assert.Equal(t, "", info)
+	assert.False(t, s.Next())
+}
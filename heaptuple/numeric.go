@@ -0,0 +1,216 @@
+package heaptuple
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// The header bits of NumericData, see utils/adt/numeric.c.
+const (
+	NUMERIC_SIGN_MASK = 0xC000
+	NUMERIC_POS       = 0x0000
+	NUMERIC_NEG       = 0x4000
+	NUMERIC_SHORT     = 0x8000
+	NUMERIC_SPECIAL   = 0xC000
+
+	NUMERIC_EXT_SIGN_MASK = 0xF000
+	NUMERIC_NAN           = 0xC000
+	NUMERIC_PINF          = 0xD000
+	NUMERIC_NINF          = 0xF000
+
+	NUMERIC_SHORT_SIGN_MASK        = 0x2000
+	NUMERIC_SHORT_DSCALE_MASK      = 0x1F80
+	NUMERIC_SHORT_DSCALE_SHIFT     = 7
+	NUMERIC_SHORT_WEIGHT_SIGN_MASK = 0x0040
+	NUMERIC_SHORT_WEIGHT_MASK      = 0x003F
+
+	NUMERIC_DSCALE_MASK = 0x3FFF
+
+	numericBase      = 10000
+	numericDecDigits = 4
+)
+
+// VARHDRSZ is the size of the length word of a varlena, typmods of numeric
+// are offset by it.
+const VARHDRSZ = 4
+
+// NumericKind tells a finite numeric from the special values.
+type NumericKind uint8
+
+const (
+	NumericFinite NumericKind = iota
+	NumericNaN
+	NumericPosInf
+	NumericNegInf
+)
+
+// Numeric is a numeric value: Digits are base 10000 digits, the first one
+// weighing 10000^Weight, and Scale is the number of decimal digits shown
+// after the point.
+type Numeric struct {
+	Kind     NumericKind
+	Negative bool
+	Weight   int
+	Scale    int
+	Digits   []int16
+}
+
+// decodeNumeric decodes the data of a numeric varlena. The stored scale is
+// kept, it is the one numeric_out shows whatever the typmod of the column.
+func decodeNumeric(data []byte) (Numeric, error) {
+	if len(data) < 2 {
+		return Numeric{}, fmt.Errorf("%w: numeric of %d bytes", ErrCorruptTuple, len(data))
+	}
+	header := binary.LittleEndian.Uint16(data)
+	var (
+		ret    Numeric
+		digits []byte
+	)
+	switch header & NUMERIC_SIGN_MASK {
+	case NUMERIC_SPECIAL:
+		switch header & NUMERIC_EXT_SIGN_MASK {
+		case NUMERIC_NAN:
+			return Numeric{Kind: NumericNaN}, nil
+		case NUMERIC_PINF:
+			return Numeric{Kind: NumericPosInf}, nil
+		case NUMERIC_NINF:
+			return Numeric{Kind: NumericNegInf}, nil
+		}
+		return Numeric{}, fmt.Errorf("%w: numeric header %#04x", ErrCorruptTuple, header)
+	case NUMERIC_SHORT:
+		ret.Negative = header&NUMERIC_SHORT_SIGN_MASK != 0
+		ret.Scale = int(header&NUMERIC_SHORT_DSCALE_MASK) >> NUMERIC_SHORT_DSCALE_SHIFT
+		ret.Weight = int(header & NUMERIC_SHORT_WEIGHT_MASK)
+		if header&NUMERIC_SHORT_WEIGHT_SIGN_MASK != 0 {
+			ret.Weight -= NUMERIC_SHORT_WEIGHT_MASK + 1
+		}
+		digits = data[2:]
+	default:
+		if len(data) < 4 {
+			return Numeric{}, fmt.Errorf("%w: numeric of %d bytes", ErrCorruptTuple, len(data))
+		}
+		ret.Negative = header&NUMERIC_SIGN_MASK == NUMERIC_NEG
+		ret.Scale = int(header & NUMERIC_DSCALE_MASK)
+		ret.Weight = int(int16(binary.LittleEndian.Uint16(data[2:])))
+		digits = data[4:]
+	}
+	if len(digits)%2 != 0 {
+		return Numeric{}, fmt.Errorf("%w: numeric digits of %d bytes", ErrCorruptTuple, len(digits))
+	}
+	for i := 0; i < len(digits); i += 2 {
+		digit := int16(binary.LittleEndian.Uint16(digits[i:]))
+		if digit < 0 || digit >= numericBase {
+			return Numeric{}, fmt.Errorf("%w: numeric digit %d", ErrCorruptTuple, digit)
+		}
+		ret.Digits = append(ret.Digits, digit)
+	}
+	return ret, nil
+}
+
+// parseNumeric parses the output of numeric_out, as found in attmissingval,
+// into the Numeric decodeNumeric returns for the same value.
+func parseNumeric(text string) (Numeric, error) {
+	switch text {
+	case "NaN":
+		return Numeric{Kind: NumericNaN}, nil
+	case "Infinity":
+		return Numeric{Kind: NumericPosInf}, nil
+	case "-Infinity":
+		return Numeric{Kind: NumericNegInf}, nil
+	}
+	var ret Numeric
+	s := text
+	if strings.HasPrefix(s, "-") {
+		ret.Negative, s = true, s[1:]
+	}
+	intPart, fracPart := s, ""
+	if idx := strings.IndexByte(s, '.'); idx >= 0 {
+		intPart, fracPart = s[:idx], s[idx+1:]
+	}
+	if intPart == "" || strings.Trim(intPart+fracPart, "0123456789") != "" {
+		return Numeric{}, fmt.Errorf("invalid numeric %q", text)
+	}
+	ret.Scale = len(fracPart)
+
+	// cut the digits in groups of 4 around the decimal point
+	intPart = strings.TrimLeft(intPart, "0")
+	for len(intPart)%numericDecDigits != 0 {
+		intPart = "0" + intPart
+	}
+	for len(fracPart)%numericDecDigits != 0 {
+		fracPart += "0"
+	}
+	all := intPart + fracPart
+	ret.Weight = len(intPart)/numericDecDigits - 1
+	for i := 0; i < len(all); i += numericDecDigits {
+		var digit int16
+		for _, c := range all[i : i+numericDecDigits] {
+			digit = digit*10 + int16(c-'0')
+		}
+		ret.Digits = append(ret.Digits, digit)
+	}
+	// strip the zero digits like make_result
+	for len(ret.Digits) > 0 && ret.Digits[0] == 0 {
+		ret.Digits, ret.Weight = ret.Digits[1:], ret.Weight-1
+	}
+	for len(ret.Digits) > 0 && ret.Digits[len(ret.Digits)-1] == 0 {
+		ret.Digits = ret.Digits[:len(ret.Digits)-1]
+	}
+	if len(ret.Digits) == 0 {
+		ret.Digits, ret.Weight, ret.Negative = nil, 0, false
+	}
+	return ret, nil
+}
+
+// String is numeric_out.
+func (n Numeric) String() string {
+	switch n.Kind {
+	case NumericNaN:
+		return "NaN"
+	case NumericPosInf:
+		return "Infinity"
+	case NumericNegInf:
+		return "-Infinity"
+	}
+	digit := func(idx int) int16 {
+		if idx < 0 || idx >= len(n.Digits) {
+			return 0
+		}
+		return n.Digits[idx]
+	}
+
+	var b strings.Builder
+	if n.Negative {
+		b.WriteByte('-')
+	}
+	if n.Weight < 0 {
+		b.WriteByte('0')
+	} else {
+		fmt.Fprintf(&b, "%d", digit(0))
+		for idx := 1; idx <= n.Weight; idx++ {
+			fmt.Fprintf(&b, "%04d", digit(idx))
+		}
+	}
+	if n.Scale <= 0 {
+		return b.String()
+	}
+	var frac strings.Builder
+	for idx := n.Weight + 1; frac.Len() < n.Scale; idx++ {
+		fmt.Fprintf(&frac, "%04d", digit(idx))
+	}
+	b.WriteByte('.')
+	b.WriteString(frac.String()[:n.Scale])
+	return b.String()
+}
+
+// Rat returns the exact value of a finite numeric, nil for the special
+// values.
+func (n Numeric) Rat() *big.Rat {
+	if n.Kind != NumericFinite {
+		return nil
+	}
+	ret, _ := new(big.Rat).SetString(n.String())
+	return ret
+}
@@ -0,0 +1,69 @@
+package heaptuple
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeNumeric(t *testing.T) {
+	cases := []struct {
+		name     string
+		data     []byte
+		expected string
+	}{
+		{"short", []byte{0x00, 0x81, 0x7B, 0x00, 0x94, 0x11}, "123.45"},
+		{"short negative weight", []byte{0x7F, 0x82, 0x01, 0x00}, "0.0001"},
+		{"trailing zero digits stripped", []byte{0x01, 0x80, 0x01, 0x00}, "10000"},
+		{"long", []byte{0x03, 0x40, 0xFF, 0xFF, 0x88, 0x13}, "-0.500"},
+		{"zero", []byte{0x00, 0x80}, "0"},
+		{"stored scale beyond the digits", []byte{0x80, 0x81, 0x7B, 0x00, 0x94, 0x11}, "123.450"},
+		{"NaN", []byte{0x00, 0xC0}, "NaN"},
+		{"infinity", []byte{0x00, 0xD0}, "Infinity"},
+		{"negative infinity", []byte{0x00, 0xF0}, "-Infinity"},
+	}
+	for _, c := range cases {
+		n, err := decodeNumeric(c.data)
+		require.NoError(t, err, c.name)
+		assert.Equal(t, c.expected, n.String(), c.name)
+		// attmissingval in text form decodes to the same value
+		parsed, err := parseNumeric(c.expected)
+		require.NoError(t, err, c.name)
+		assert.Equal(t, n, parsed, c.name)
+	}
+	for _, text := range []string{"", "-", "1.2.3", "1e5", "abc"} {
+		_, err := parseNumeric(text)
+		assert.Error(t, err, text)
+	}
+
+	n, err := decodeNumeric([]byte{0x00, 0x81, 0x7B, 0x00, 0x94, 0x11})
+	require.NoError(t, err)
+	assert.Equal(t, big.NewRat(2469, 20), n.Rat())
+	n, err = decodeNumeric([]byte{0x00, 0xC0})
+	require.NoError(t, err)
+	assert.Nil(t, n.Rat())
+
+	for _, data := range [][]byte{{0x00}, {0x00, 0x40, 0x00}, {0x00, 0x80, 0x10, 0x27}, {0x00, 0xE0}} {
+		_, err := decodeNumeric(data)
+		assert.ErrorIs(t, err, ErrCorruptTuple)
+	}
+
+	alignments := []AttrAlign{{AttName: "price", TypName: "numeric", TypAlign: "i", TypLen: -1, TypMod: -1}}
+	row, err := ParseTupleData(alignments, &TupleHeader{Infomask2: 1}, []byte{0x0F, 0x00, 0x81, 0x7B, 0x00, 0x94, 0x11})
+	require.NoError(t, err)
+	assert.Equal(t, "123.45", row[0].String())
+	// numeric(10,3) rounds on input, the stored scale is what is shown
+	constrained := []AttrAlign{{AttName: "price", TypName: "numeric", TypAlign: "i", TypLen: -1, TypMod: 10<<16 | 3 + VARHDRSZ}}
+	row, err = ParseTupleData(constrained, &TupleHeader{Infomask2: 1}, []byte{0x0F, 0x80, 0x81, 0x7B, 0x00, 0x94, 0x11})
+	require.NoError(t, err)
+	assert.Equal(t, "123.450", row[0].String())
+
+	missing := []AttrAlign{{AttName: "price", TypName: "numeric", TypAlign: "i", TypLen: -1, TypMod: -1,
+		HasMissing: true, MissingVal: "123.45"}}
+	row, err = ParseTupleData(missing, &TupleHeader{}, nil)
+	require.NoError(t, err)
+	require.IsType(t, Numeric{}, row[0].Value)
+	assert.Equal(t, big.NewRat(2469, 20), row[0].Value.(Numeric).Rat())
+}
@@ -2,6 +2,7 @@ package heaptuple
 
 import (
 	"fmt"
+	"sort"
 	"unsafe"
 )
 
@@ -21,20 +22,22 @@ type TupleHeader struct {
 }
 
 func (th TupleHeader) HasNullBits() bool {
-	return th.Infomask&0x0001 != 0
+	return th.Infomask&HEAP_HASNULL != 0
 }
 
 func (th TupleHeader) AttrCnt() uint16 {
 	if len(th.NullBits) > 0 {
 		return uint16(len(th.NullBits))
 	}
-	return th.Infomask2 & 0x07FF
+	return th.Infomask2 & HEAP_NATTS_MASK
 }
 
 type Tuple struct {
-	Header          TupleHeader
-	Data            map[string]string
-	ExtraToastField map[string]EXTERNAL
+	Header TupleHeader
+	Data   Row
+	// Err is set when the tuple could not be deformed, the other tuples of
+	// the page are still decoded.
+	Err error
 }
 
 func ParseTupleHeader(bins []byte) TupleHeader {
@@ -44,11 +47,11 @@ func ParseTupleHeader(bins []byte) TupleHeader {
 }
 
 func ParseTupleHeader2(th *TupleHeader, bins []byte) {
-	hasNulls := th.Infomask&0x0001 != 0
+	hasNulls := th.Infomask&HEAP_HASNULL != 0
 	if !hasNulls {
 		return
 	}
-	attrCnt := th.Infomask2 & 0x07FF
+	attrCnt := th.Infomask2 & HEAP_NATTS_MASK
 	th.NullBits = make([]byte, attrCnt)
 	for i := uint16(0); i < attrCnt; i++ {
 		byteIdx := i / 8
@@ -57,75 +60,23 @@ func ParseTupleHeader2(th *TupleHeader, bins []byte) {
 	}
 }
 
-func ParseTupleData(alignments []AttrAlign, th *TupleHeader, bins []byte) (map[string]string, map[string]EXTERNAL, error) {
-	parseKV := func(idx, offset int) (k string, v string, typ EXTERNAL, nextOffset int) {
-		getNextOffset := func() int {
-			if idx == len(alignments)-1 {
-				return -1
-			}
+// XLogRecPtr is a position in the WAL.
+type XLogRecPtr uint64
 
-			item := alignments[idx]
-			nextItem := alignments[idx+1]
-			padding := map[string]int{
-				"c": 1,
-				"s": 2,
-				"i": 4,
-				"d": 8,
-			}
-			nextPadding, ok := padding[nextItem.TypAlign]
-			if !ok {
-				panic("unknown alignment rules")
-			}
-			nextOffset := offset + item.TypLen
-			for nextOffset%nextPadding != 0 {
-				nextOffset++
-			}
-			return nextOffset
-		}
-		item := alignments[idx]
-		switch item.TypName {
-		case "oid":
-			var (
-				v     uint32
-				bytes = bins[offset : offset+item.TypLen]
-			)
-			v = **(**uint32)(unsafe.Pointer(&bytes))
-			return item.AttName, fmt.Sprintf("%d", v), VARTAG_UNUSED, getNextOffset()
-		case "int4":
-			var (
-				v     int32
-				bytes = bins[offset : offset+item.TypLen]
-			)
-			v = **(**int32)(unsafe.Pointer(&bytes))
-			return item.AttName, fmt.Sprintf("%d", v), VARTAG_UNUSED, getNextOffset()
-		case "bytea", "text":
-			text := ParseVarlena(bins[offset:])
-			alignments[idx].TypLen = text.GetLength()
-			return item.AttName, string(text.GetData()), text.GetType(), getNextOffset()
-		}
-		panic("does not support")
-	}
-
-	var (
-		kv     = make(map[string]string)
-		extra  = make(map[string]EXTERNAL)
-		k, v   string
-		typ    EXTERNAL
-		offset int
-	)
-	for i := 0; i < int(th.AttrCnt()); i++ {
-		if th.HasNullBits() && th.NullBits[i] == 0 {
-			kv[alignments[i].AttName] = "NULL"
-			extra[alignments[i].AttName] = VARTAG_UNUSED
-			continue
-		}
-		k, v, typ, offset = parseKV(i, offset)
-		kv[k] = v
-		extra[k] = typ
-	}
-	return kv, extra, nil
+func (p XLogRecPtr) String() string {
+	return fmt.Sprintf("%X/%X", uint32(p>>32), uint32(p))
 }
 
+type TransactionId uint32
+
+// pd_flags
+const (
+	PD_HAS_FREE_LINES  = 0x0001 // are there any unused line pointers?
+	PD_PAGE_FULL       = 0x0002 // not enough free space for new tuple?
+	PD_ALL_VISIBLE     = 0x0004 // all tuples on page are visible to everyone
+	PD_VALID_FLAG_BITS = 0x0007
+)
+
 type PageHeader struct {
 	Lsn             [8]byte
 	Checksum        uint16
@@ -137,9 +88,68 @@ type PageHeader struct {
 	PruneXid        [4]byte
 }
 
+// LSN returns pd_lsn, the end of the last WAL record that changed the page.
+func (h PageHeader) LSN() XLogRecPtr {
+	xlogid := **(**uint32)(unsafe.Pointer(&[]byte{h.Lsn[0], h.Lsn[1], h.Lsn[2], h.Lsn[3]}))
+	xrecoff := **(**uint32)(unsafe.Pointer(&[]byte{h.Lsn[4], h.Lsn[5], h.Lsn[6], h.Lsn[7]}))
+	return XLogRecPtr(uint64(xlogid)<<32 | uint64(xrecoff))
+}
+
+func (h PageHeader) HasFreeLines() bool {
+	return h.Flags&PD_HAS_FREE_LINES != 0
+}
+
+func (h PageHeader) IsFull() bool {
+	return h.Flags&PD_PAGE_FULL != 0
+}
+
+func (h PageHeader) IsAllVisible() bool {
+	return h.Flags&PD_ALL_VISIBLE != 0
+}
+
+// PageSize returns the page size stored in the high byte of
+// pd_pagesize_version.
+func (h PageHeader) PageSize() int {
+	return int(h.PagesizeVersion & 0xFF00)
+}
+
+// LayoutVersion returns the page layout version, 4 since PostgreSQL 8.3.
+func (h PageHeader) LayoutVersion() uint8 {
+	return uint8(h.PagesizeVersion & 0x00FF)
+}
+
+// GetPruneXid returns pd_prune_xid, the oldest unpruned XMAX on the page or
+// zero if none.
+func (h PageHeader) GetPruneXid() TransactionId {
+	return TransactionId(**(**uint32)(unsafe.Pointer(&[]byte{h.PruneXid[0], h.PruneXid[1], h.PruneXid[2], h.PruneXid[3]})))
+}
+
+// IsNew is PageIsNew, an all-zero page left by relation extension that was
+// never initialized. It holds no line pointer.
+func (h PageHeader) IsNew() bool {
+	return h.Upper == 0
+}
+
+// Validate checks the header against the block size the page was read with.
+func (h PageHeader) Validate(blockSize int) error {
+	if h.PageSize() != blockSize {
+		return fmt.Errorf("%w: page size %d in header does not match block size %d", ErrCorruptPage, h.PageSize(), blockSize)
+	}
+	return nil
+}
+
+type LPFLAG = uint8
+
+const (
+	LP_UNUSED   LPFLAG = 0 // unused, lp_len is always 0
+	LP_NORMAL   LPFLAG = 1 // used, lp_len is always > 0
+	LP_REDIRECT LPFLAG = 2 // HOT redirect, lp_off is the target line pointer
+	LP_DEAD     LPFLAG = 3 // dead, may or may not have storage
+)
+
 type SlotID struct {
 	// 15bits:  offset to tuple (from start of page)
-	// 2bits: ignore
+	// 2bits: state of the line pointer, LP_*
 	// 15bits:  byte length of tuple
 	content uint32
 }
@@ -148,6 +158,22 @@ func (s SlotID) GetTupleOffset() uint16 {
 	return uint16(s.content & 0x7FFF)
 }
 
+func (s SlotID) GetFlags() LPFLAG {
+	return LPFLAG((s.content >> 15) & 0x03)
+}
+
+// GetRedirect returns the 1-based line pointer an LP_REDIRECT slot points
+// to.
+func (s SlotID) GetRedirect() uint16 {
+	return s.GetTupleOffset()
+}
+
+// HasStorage reports whether the slot points at tuple bytes, true for
+// LP_NORMAL and for LP_DEAD items not yet pruned.
+func (s SlotID) HasStorage() bool {
+	return s.GetTupleLength() != 0
+}
+
 func (s SlotID) GetTupleLength() uint16 {
 	return uint16((s.content >> 17) & 0x7FFF)
 }
@@ -163,16 +189,47 @@ func (s SlotID) GetTupleSize() uint16 {
 type Page struct {
 	Header PageHeader
 	Slots  []SlotID
+	// Tuples is parallel to Slots, only the tuples of LP_NORMAL slots, and
+	// of LP_DEAD slots with storage when asked for, are decoded.
 	Tuples []Tuple
 }
 
+// ReadOptions tunes how ReadPageWithOptions decodes a page.
+type ReadOptions struct {
+	// Dead also decodes LP_DEAD items that still have storage.
+	Dead bool
+	// VerifyChecksum checks pd_checksum of every page read through a
+	// PageReader, a mismatch is reported as a *ChecksumError.
+	VerifyChecksum bool
+	// Salvage makes a PageReader quarantine damaged pages and tuples instead
+	// of failing, they are listed by its Damage method.
+	Salvage bool
+}
+
 func ReadPage(bytes []byte, alignments []AttrAlign) (page Page, err error) {
+	return ReadPageWithOptions(bytes, alignments, ReadOptions{})
+}
+
+func ReadPageWithOptions(bytes []byte, alignments []AttrAlign, opts ReadOptions) (page Page, err error) {
 	var ret Page
+	if len(bytes) < 24 {
+		return Page{}, newCorruptionError(fmt.Errorf("%w: page of %d bytes is shorter than its header", ErrCorruptPage, len(bytes)), "", -1)
+	}
 	f := bytes
 	headerBytes := f[0:24]
 	f = f[24:]
 	header := **(**PageHeader)(unsafe.Pointer(&headerBytes))
 	ret.Header = header
+	// left behind by relation extension, there is nothing to validate
+	if header.IsNew() {
+		return ret, nil
+	}
+	if err := header.Validate(len(bytes)); err != nil {
+		return Page{}, newCorruptionError(err, "", 18)
+	}
+	if err := header.check(len(bytes)); err != nil {
+		return Page{}, err
+	}
 
 	slotCnt := (ret.Header.Lower - 24) / 4
 	ret.Slots = make([]SlotID, slotCnt)
@@ -180,21 +237,106 @@ func ReadPage(bytes []byte, alignments []AttrAlign) (page Page, err error) {
 	for idx := range ret.Slots {
 		slotBytes := f[:4]
 		f = f[4:]
-		slot := **(**SlotID)(unsafe.Pointer(&slotBytes))
-		ret.Slots[idx] = slot
-
-		tOffset := slot.GetTupleOffset()
-		tHeader := ParseTupleHeader(bytes[tOffset : tOffset+23])
-		if tHeader.HasNullBits() {
-			ParseTupleHeader2(&tHeader, bytes[tOffset+23:tOffset+uint16(tHeader.Hoff)])
+		ret.Slots[idx] = **(**SlotID)(unsafe.Pointer(&slotBytes))
+	}
+	if err := checkOverlap(header, ret.Slots); err != nil {
+		return Page{}, err
+	}
+	for idx, slot := range ret.Slots {
+		switch slot.GetFlags() {
+		case LP_NORMAL:
+		case LP_DEAD:
+			if !opts.Dead || !slot.HasStorage() {
+				continue
+			}
+		default:
+			continue
 		}
-		ret.Tuples[idx] = Tuple{Header: tHeader}
-		tData, tExtra, err := ParseTupleData(alignments, &tHeader, bytes[tOffset+uint16(tHeader.Hoff):])
-		if err != nil {
-			return Page{}, err
+
+		ret.Tuples[idx] = readTuple(bytes, slot, alignments)
+		if ce, ok := ret.Tuples[idx].Err.(*CorruptionError); ok {
+			ce.LinePointer = uint16(idx + 1)
 		}
-		ret.Tuples[idx].Data = tData
-		ret.Tuples[idx].ExtraToastField = tExtra
 	}
 	return ret, nil
 }
+
+// check is the sanity check of PageIsVerified on the header.
+func (h PageHeader) check(pageSize int) error {
+	switch {
+	case h.Lower < 24 || h.Lower > h.Upper:
+		return newCorruptionError(fmt.Errorf("%w: pd_lower %d, pd_upper %d", ErrCorruptPage, h.Lower, h.Upper), "", 12)
+	case h.Upper > h.Special:
+		return newCorruptionError(fmt.Errorf("%w: pd_upper %d beyond pd_special %d", ErrCorruptPage, h.Upper, h.Special), "", 14)
+	case int(h.Special) > pageSize:
+		return newCorruptionError(fmt.Errorf("%w: pd_special %d beyond page size %d", ErrCorruptPage, h.Special, pageSize), "", 16)
+	}
+	return nil
+}
+
+// checkOverlap fails when the storage of two line pointers overlap. Those
+// pointing out of the tuple space are left to readTuple.
+func checkOverlap(header PageHeader, slots []SlotID) error {
+	type item struct {
+		idx        int
+		start, end int
+	}
+	var items []item
+	for idx, slot := range slots {
+		if !slot.HasStorage() || slot.GetFlags() == LP_REDIRECT {
+			continue
+		}
+		start := int(slot.GetTupleOffset())
+		end := start + int(slot.GetTupleLength())
+		if start < int(header.Upper) || end > int(header.Special) {
+			continue
+		}
+		items = append(items, item{idx: idx, start: start, end: end})
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].start < items[j].start })
+	for i := 1; i < len(items); i++ {
+		if items[i].start < items[i-1].end {
+			err := newCorruptionError(fmt.Errorf("%w: line pointers %d and %d overlap", ErrCorruptPage, items[i-1].idx+1, items[i].idx+1), "", 24+4*items[i].idx)
+			err.LinePointer = uint16(items[i].idx + 1)
+			return err
+		}
+	}
+	return nil
+}
+
+// readTuple deforms the tuple slot points at, a failure is left in the Err
+// of the returned tuple.
+func readTuple(page []byte, slot SlotID, alignments []AttrAlign) Tuple {
+	const sizeofHeapTupleHeader = 23
+	tOffset, tLength := int(slot.GetTupleOffset()), int(slot.GetTupleLength())
+	if tLength < sizeofHeapTupleHeader || tOffset+tLength > len(page) {
+		return Tuple{Err: newCorruptionError(fmt.Errorf("%w: line pointer to %d bytes at %d out of page", ErrCorruptTuple, tLength, tOffset), "", tOffset)}
+	}
+	tuple := page[tOffset : tOffset+tLength]
+	tHeader := ParseTupleHeader(tuple[:sizeofHeapTupleHeader])
+	hoff := int(tHeader.Hoff)
+	bitmapLen := 0
+	if tHeader.HasNullBits() {
+		bitmapLen = (int(tHeader.Infomask2&HEAP_NATTS_MASK) + 7) / 8
+	}
+	if hoff < sizeofHeapTupleHeader+bitmapLen || hoff > tLength {
+		return Tuple{Header: tHeader, Err: newCorruptionError(fmt.Errorf("%w: t_hoff %d out of range", ErrCorruptTuple, hoff), "", tOffset+22)}
+	}
+	if tHeader.HasNullBits() {
+		ParseTupleHeader2(&tHeader, tuple[sizeofHeapTupleHeader:hoff])
+	}
+	tData, err := ParseTupleData(alignments, &tHeader, tuple[hoff:])
+	if err != nil {
+		if ce, ok := err.(*CorruptionError); ok && ce.Offset >= 0 {
+			ce.Offset += tOffset + hoff
+		}
+		return Tuple{Header: tHeader, Err: err}
+	}
+	return Tuple{Header: tHeader, Data: tData}
+}
+
+// IsNormal reports whether the idx-th slot holds a live tuple, as opposed to
+// an unused, redirect or dead line pointer.
+func (p Page) IsNormal(idx int) bool {
+	return p.Slots[idx].GetFlags() == LP_NORMAL
+}
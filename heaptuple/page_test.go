@@ -2,6 +2,7 @@ package heaptuple
 
 import (
 	"context"
+	"encoding/binary"
 	"fmt"
 	"os"
 	"testing"
@@ -10,54 +11,55 @@ import (
 
 	"github.com/jackc/pgx/v5"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 var (
-	table Table
+	table     Table
+	selfFile  HeapFile
+	toastFile HeapFile
 )
 
 func TestHeapFileCnt(t *testing.T) {
-	assert.Lenf(t, table.selfFiles, 1, "expected 1, got %d", len(table.selfFiles))
+	segments := table.self.Segments(MainForkNum)
+	assert.Lenf(t, segments, 1, "expected 1, got %d", len(segments))
 }
 
 func TestToastHeapFileCnt(t *testing.T) {
-	assert.Lenf(t, table.toastFiles, 1, "expected 1, got %d", len(table.toastFiles))
+	segments := table.toast.Segments(MainForkNum)
+	assert.Lenf(t, segments, 1, "expected 1, got %d", len(segments))
 }
 
 func TestPageCnt(t *testing.T) {
-	var sum int
-	for _, f := range table.selfFiles {
-		sum += len(f.Pages)
-	}
+	sum := len(selfFile.Pages)
 	assert.Equalf(t, sum, 1, "expected 1, got %d", sum)
+	assert.EqualValues(t, sum, table.self.NBlocks(MainForkNum))
 }
 
 func TestToastPageCnt(t *testing.T) {
-	var sum int
-	for _, f := range table.toastFiles {
-		sum += len(f.Pages)
-	}
+	sum := len(toastFile.Pages)
 	assert.Equalf(t, sum, 1, "expected 1, got %d", sum)
+	assert.EqualValues(t, sum, table.toast.NBlocks(MainForkNum))
 }
 
 func TestTupleCnt(t *testing.T) {
-	assert.Lenf(t, table.selfFiles[0].Pages[0].Slots, 6, "expected 6, got %d", len(table.selfFiles[0].Pages[0].Slots))
+	assert.Lenf(t, selfFile.Pages[0].Slots, 6, "expected 6, got %d", len(selfFile.Pages[0].Slots))
 }
 
 func TestSlotOffset(t *testing.T) {
-	slotCnt := len(table.selfFiles[0].Pages[0].Slots)
-	lastSlot := table.selfFiles[0].Pages[0].Slots[slotCnt-1]
-	assert.EqualValues(t, lastSlot.GetTupleOffset(), table.selfFiles[0].Pages[0].Header.Upper)
+	slotCnt := len(selfFile.Pages[0].Slots)
+	lastSlot := selfFile.Pages[0].Slots[slotCnt-1]
+	assert.EqualValues(t, lastSlot.GetTupleOffset(), selfFile.Pages[0].Header.Upper)
 }
 
 func TestSlotLength(t *testing.T) {
-	firstSlot := table.selfFiles[0].Pages[0].Slots[0]
-	assert.Equal(t, firstSlot.GetTupleOffset()+firstSlot.GetTupleSize(), table.selfFiles[0].Pages[0].Header.Special)
+	firstSlot := selfFile.Pages[0].Slots[0]
+	assert.Equal(t, firstSlot.GetTupleOffset()+firstSlot.GetTupleSize(), selfFile.Pages[0].Header.Special)
 }
 
 // Nullbits map is generated by other fields, so just check it
 func TestTupleHeader(t *testing.T) {
-	firstTupleHeader := table.selfFiles[0].Pages[0].Tuples[0].Header
+	firstTupleHeader := selfFile.Pages[0].Tuples[0].Header
 	notNullMapper := map[int]uint8{
 		0: 1,
 		1: 1,
@@ -68,7 +70,7 @@ func TestTupleHeader(t *testing.T) {
 }
 
 func TestTupleDataInt(t *testing.T) {
-	secondTupleData := table.selfFiles[0].Pages[0].Tuples[1].Data
+	secondTupleData := selfFile.Pages[0].Tuples[1].Data.Strings()
 	notNullMapper := map[string]string{
 		"id":  "2",
 		"f1":  "2",
@@ -86,7 +88,7 @@ func TestTupleDataInt(t *testing.T) {
 }
 
 func TestTupleDataTextVarattrib1B(t *testing.T) {
-	firstTupleData := table.selfFiles[0].Pages[0].Tuples[0].Data
+	firstTupleData := selfFile.Pages[0].Tuples[0].Data.Strings()
 	notNullMapper := map[string]string{
 		"id": "1",
 		"f1": "1",
@@ -102,7 +104,7 @@ func TestTupleDataTextVarattrib1B(t *testing.T) {
 }
 
 func TestTupleDataTextVarattrib4BNoCompressed(t *testing.T) {
-	foutrhTupleData := table.selfFiles[0].Pages[0].Tuples[3].Data
+	foutrhTupleData := selfFile.Pages[0].Tuples[3].Data.Strings()
 	notNullMapper := map[string]string{
 		"id":  "4",
 		"f15": testdata.Data156,
@@ -118,7 +120,7 @@ func TestTupleDataTextVarattrib4BNoCompressed(t *testing.T) {
 }
 
 func TestTupleDataTextVarattrib4BCompressed(t *testing.T) {
-	foutrhTupleData := table.selfFiles[0].Pages[0].Tuples[4].Data
+	foutrhTupleData := selfFile.Pages[0].Tuples[4].Data.Strings()
 	notNullMapper := map[string]string{
 		"id":  "5",
 		"f15": testdata.Data3120,
@@ -134,8 +136,12 @@ func TestTupleDataTextVarattrib4BCompressed(t *testing.T) {
 }
 
 func TestTupleDataTextToastOnDisk(t *testing.T) {
-	tuples := table.GetTuples()
-	for _, tpData := range tuples {
+	tuples, err := table.GetTuples()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range tuples {
+		tpData := row.Strings()
 		if tpData["id"] != fmt.Sprintf("6") {
 			continue
 		}
@@ -145,14 +151,53 @@ func TestTupleDataTextToastOnDisk(t *testing.T) {
 	t.Fatalf("should reach here")
 }
 
+// The pd_checksum the server wrote is the known good value, the cluster must
+// have been initialized with data checksums.
+func TestPageChecksumOnDisk(t *testing.T) {
+	ctx := context.Background()
+	conn, err := pgx.Connect(ctx, "postgres://localhost:8432/litianxiang")
+	require.NoError(t, err)
+	defer conn.Close(ctx)
+
+	var enabled string
+	require.NoError(t, conn.QueryRow(ctx, "SHOW data_checksums").Scan(&enabled))
+	if enabled != "on" {
+		t.Skip("data checksums are disabled")
+	}
+	// the checksum is set when the page is written out
+	_, err = conn.Exec(ctx, "CHECKPOINT")
+	require.NoError(t, err)
+	for _, rel := range []Relation{table.self, table.toast} {
+		for blkno := uint32(0); blkno < rel.NBlocks(MainForkNum); blkno++ {
+			page, err := rel.ReadBlock(MainForkNum, blkno)
+			require.NoError(t, err)
+			assert.Equal(t, binary.LittleEndian.Uint16(page[checksumOffset:]), PageChecksum(page, blkno), "%s block %d", rel.Path, blkno)
+		}
+	}
+}
+
 func TestMain(m *testing.M) {
 	// PrepareDataPanic()
 
-	t, err := NewTable("test")
+	ctx := context.Background()
+	src, err := ConnectCatalog(ctx, "postgres://localhost:8432/litianxiang")
+	if err != nil {
+		panic(err)
+	}
+	t, err := NewTable(ctx, src, "test")
+	src.Close(ctx)
 	if err != nil {
 		panic(err)
 	}
 	table = t
+	selfFile, err = ReadHeapFile(t.self.Path, t.self.BlockSize, t.selfAttrAlign)
+	if err != nil {
+		panic(err)
+	}
+	toastFile, err = ReadHeapFile(t.toast.Path, t.toast.BlockSize, t.toastAttrAlign)
+	if err != nil {
+		panic(err)
+	}
 
 	os.Exit(m.Run())
 }
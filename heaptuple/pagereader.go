@@ -0,0 +1,256 @@
+package heaptuple
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// PageReader reads the blocks of one file on demand, only a single block is
+// held in memory at a time.
+type PageReader struct {
+	r          io.ReaderAt
+	size       int64
+	blockSize  int
+	alignments []AttrAlign
+	opts       ReadOptions
+	seg        Segment
+	buf        []byte
+	damage     []DamagedItem
+
+	next  uint32
+	blkno uint32
+	page  Page
+	err   error
+}
+
+func NewPageReader(r io.ReaderAt, size int64, blockSize int, alignments []AttrAlign) *PageReader {
+	return &PageReader{
+		r:          r,
+		size:       size,
+		blockSize:  blockSize,
+		alignments: alignments,
+		buf:        make([]byte, blockSize),
+	}
+}
+
+func (pr *PageReader) SetOptions(opts ReadOptions) {
+	pr.opts = opts
+}
+
+// SetSegment tells which segment of its relation the file is, checksums
+// depend on the block number inside the whole fork.
+func (pr *PageReader) SetSegment(seg Segment) {
+	pr.seg = seg
+}
+
+// NBlocks returns the number of blocks, a trailing partial block counts as
+// one.
+func (pr *PageReader) NBlocks() uint32 {
+	return uint32((pr.size + int64(pr.blockSize) - 1) / int64(pr.blockSize))
+}
+
+// ReadBlock returns the raw content of block blkno. The returned slice is
+// reused by the next read.
+func (pr *PageReader) ReadBlock(blkno uint32) ([]byte, error) {
+	if blkno >= pr.NBlocks() {
+		return nil, fmt.Errorf("block %d out of range, %d blocks", blkno, pr.NBlocks())
+	}
+	n, err := pr.r.ReadAt(pr.buf, int64(blkno)*int64(pr.blockSize))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return pr.buf[:n], nil
+}
+
+// TruncatedPageError reports a trailing block shorter than the block size,
+// as left by a crash while the relation was being extended. The blocks before
+// it are readable.
+type TruncatedPageError struct {
+	Path      string
+	Block     uint32
+	Bytes     int
+	BlockSize int
+}
+
+func (e *TruncatedPageError) Error() string {
+	return fmt.Sprintf("%s: block %d truncated, read %d of %d bytes", e.Path, e.Block, e.Bytes, e.BlockSize)
+}
+
+// ReadPage reads and decodes block blkno. New pages decode as empty pages, a
+// partial block fails with a *TruncatedPageError.
+//
+// With the Salvage option a damaged page, truncated or failing its checks,
+// decodes as an empty page and is recorded with the damaged tuples in
+// Damage.
+func (pr *PageReader) ReadPage(blkno uint32) (Page, error) {
+	bytes, err := pr.ReadBlock(blkno)
+	if err != nil {
+		return Page{}, err
+	}
+	page, err := pr.decode(bytes, blkno)
+	if !pr.opts.Salvage {
+		return page, err
+	}
+	if err != nil {
+		pr.damage = append(pr.damage, newDamagedItem(pr.seg.Path, pr.seg.FirstBlock+blkno, err, bytes))
+		return Page{}, nil
+	}
+	for idx, tp := range page.Tuples {
+		if tp.Err != nil {
+			pr.damage = append(pr.damage, newDamagedItem(pr.seg.Path, pr.seg.FirstBlock+blkno, tp.Err, tupleBytes(bytes, page.Slots[idx])))
+		}
+	}
+	return page, nil
+}
+
+func (pr *PageReader) decode(bytes []byte, blkno uint32) (Page, error) {
+	if len(bytes) < pr.blockSize {
+		return Page{}, &TruncatedPageError{Path: pr.seg.Path, Block: pr.seg.FirstBlock + blkno, Bytes: len(bytes), BlockSize: pr.blockSize}
+	}
+	if pr.opts.VerifyChecksum {
+		err := VerifyPageChecksum(bytes, pr.seg.FirstBlock+blkno)
+		if err, ok := err.(*ChecksumError); ok {
+			err.Path, err.Segment = pr.seg.Path, pr.seg.SegNo
+			return Page{}, err
+		}
+	}
+	page, err := ReadPageWithOptions(bytes, pr.alignments, pr.opts)
+	locate(err, pr.seg.Path, pr.seg.FirstBlock+blkno)
+	for _, tp := range page.Tuples {
+		locate(tp.Err, pr.seg.Path, pr.seg.FirstBlock+blkno)
+	}
+	return page, err
+}
+
+// Damage returns what the Salvage option skipped so far.
+func (pr *PageReader) Damage() []DamagedItem {
+	return pr.damage
+}
+
+// Next decodes the next block, it returns false at the end of the file or
+// on error.
+func (pr *PageReader) Next() bool {
+	if pr.err != nil || pr.next >= pr.NBlocks() {
+		return false
+	}
+	pr.blkno = pr.next
+	pr.page, pr.err = pr.ReadPage(pr.blkno)
+	pr.next++
+	return pr.err == nil
+}
+
+// Page returns the page decoded by the last call to Next.
+func (pr *PageReader) Page() Page {
+	return pr.page
+}
+
+// BlockNumber returns the block number of Page inside the file.
+func (pr *PageReader) BlockNumber() uint32 {
+	return pr.blkno
+}
+
+func (pr *PageReader) Err() error {
+	return pr.err
+}
+
+// RelationPages walks the pages of a fork through all of its segments, block
+// numbers are global to the fork.
+type RelationPages struct {
+	rel        Relation
+	fork       ForkNumber
+	alignments []AttrAlign
+	opts       ReadOptions
+
+	segIdx int
+	f      *os.File
+	pr     *PageReader
+	err    error
+	damage []DamagedItem
+}
+
+// Pages returns a sequential reader over the pages of fork.
+func (r Relation) Pages(fork ForkNumber, alignments []AttrAlign) *RelationPages {
+	return &RelationPages{rel: r, fork: fork, alignments: alignments}
+}
+
+// SetOptions changes how the pages are decoded, it must be called before the
+// first call to Next.
+func (rp *RelationPages) SetOptions(opts ReadOptions) {
+	rp.opts = opts
+}
+
+func (rp *RelationPages) Next() bool {
+	segments := rp.rel.Segments(rp.fork)
+	for rp.err == nil {
+		if rp.pr != nil && rp.pr.Next() {
+			return true
+		}
+		if rp.pr != nil {
+			rp.err = rp.pr.Err()
+			rp.closeSegment()
+			rp.segIdx++
+			continue
+		}
+		if rp.segIdx >= len(segments) {
+			return false
+		}
+		rp.err = rp.openSegment(segments[rp.segIdx])
+	}
+	return false
+}
+
+func (rp *RelationPages) openSegment(seg Segment) error {
+	f, err := os.Open(seg.Path)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	rp.f = f
+	rp.pr = NewPageReader(f, info.Size(), rp.rel.BlockSize, rp.alignments)
+	rp.pr.SetOptions(rp.opts)
+	rp.pr.SetSegment(seg)
+	return nil
+}
+
+func (rp *RelationPages) closeSegment() {
+	if rp.pr != nil {
+		rp.damage = append(rp.damage, rp.pr.Damage()...)
+	}
+	if rp.f != nil {
+		rp.f.Close()
+	}
+	rp.f, rp.pr = nil, nil
+}
+
+func (rp *RelationPages) Page() Page {
+	return rp.pr.Page()
+}
+
+// BlockNumber returns the global block number of Page.
+func (rp *RelationPages) BlockNumber() uint32 {
+	return rp.rel.Segments(rp.fork)[rp.segIdx].FirstBlock + rp.pr.BlockNumber()
+}
+
+func (rp *RelationPages) Err() error {
+	return rp.err
+}
+
+// Damage returns what the Salvage option skipped so far in every segment.
+func (rp *RelationPages) Damage() []DamagedItem {
+	if rp.pr == nil {
+		return rp.damage
+	}
+	return append(append([]DamagedItem(nil), rp.damage...), rp.pr.Damage()...)
+}
+
+// Close releases the open segment, it is safe to call it before the end of
+// the walk.
+func (rp *RelationPages) Close() error {
+	rp.closeSegment()
+	return nil
+}
@@ -0,0 +1,113 @@
+package heaptuple
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPageReader(t *testing.T) {
+	alignments := []AttrAlign{{AttName: "id", TypName: "int4", TypAlign: "i", TypLen: 4}}
+	tuple := func(id int32) []byte {
+		return buildTuple(testTuple{xmin: 3, nulls: []bool{false}, data: encodeAttrs(alignments, id)})
+	}
+	file := append(buildPage(DefaultBlockSize, tuple(1), tuple(2)), buildPage(DefaultBlockSize, tuple(3))...)
+
+	pr := NewPageReader(bytes.NewReader(file), int64(len(file)), DefaultBlockSize, alignments)
+	assert.EqualValues(t, 2, pr.NBlocks())
+
+	var ids []string
+	for pr.Next() {
+		for _, tp := range pr.Page().Tuples {
+			ids = append(ids, tp.Data.Strings()["id"])
+		}
+	}
+	require.NoError(t, pr.Err())
+	assert.Equal(t, []string{"1", "2", "3"}, ids)
+	assert.EqualValues(t, 1, pr.BlockNumber())
+
+	page, err := pr.ReadPage(0)
+	require.NoError(t, err)
+	assert.Len(t, page.Tuples, 2)
+	_, err = pr.ReadPage(2)
+	assert.Error(t, err)
+
+	path := filepath.Join(t.TempDir(), "16384")
+	require.NoError(t, os.WriteFile(path, file, 0o644))
+	rel, err := OpenRelation(path, DefaultBlockSize)
+	require.NoError(t, err)
+	pages := rel.Pages(MainForkNum, alignments)
+	defer pages.Close()
+	var blocks []uint32
+	for pages.Next() {
+		blocks = append(blocks, pages.BlockNumber())
+	}
+	require.NoError(t, pages.Err())
+	assert.Equal(t, []uint32{0, 1}, blocks)
+}
+
+func TestNewAndTruncatedPages(t *testing.T) {
+	alignments := []AttrAlign{{AttName: "id", TypName: "int4", TypAlign: "i", TypLen: 4}}
+	tuple := buildTuple(testTuple{xmin: 3, nulls: []bool{false}, data: encodeAttrs(alignments, int32(1))})
+	file := append(buildPage(DefaultBlockSize, tuple), make([]byte, DefaultBlockSize)...)
+	file = append(file, buildPage(DefaultBlockSize, tuple)[:100]...)
+	path := filepath.Join(t.TempDir(), "16384")
+	require.NoError(t, os.WriteFile(path, file, 0o644))
+
+	hf, err := ReadHeapFile(path, DefaultBlockSize, alignments)
+	require.Len(t, hf.Pages, 2)
+	assert.Len(t, hf.Pages[0].Tuples, 1)
+	assert.True(t, hf.Pages[1].Header.IsNew())
+	assert.Empty(t, hf.Pages[1].Slots)
+
+	var truncated *TruncatedPageError
+	require.ErrorAs(t, err, &truncated)
+	assert.EqualValues(t, 2, truncated.Block)
+	assert.Equal(t, 100, truncated.Bytes)
+}
+
+func TestRowsOutliveTheirPage(t *testing.T) {
+	alignments := []AttrAlign{
+		{AttName: "id", TypName: "int4", TypAlign: "i", TypLen: 4},
+		{AttName: "body", TypName: "text", TypAlign: "i", TypLen: -1},
+	}
+	tuple := func(id int32, body string) []byte {
+		return buildTuple(testTuple{xmin: 3, nulls: []bool{false, false}, data: append(encodeAttrs(alignments, id), shortVarlena(body)...)})
+	}
+	path := filepath.Join(t.TempDir(), "16384")
+	require.NoError(t, os.WriteFile(path, append(append(
+		buildPage(DefaultBlockSize, tuple(10, "a")),
+		buildPage(DefaultBlockSize, tuple(11, "bb"))...),
+		buildPage(DefaultBlockSize, tuple(12, "ccc"))...), 0o644))
+
+	check := func(rows []Row) {
+		require.Len(t, rows, 3)
+		for idx, body := range []string{"a", "bb", "ccc"} {
+			assert.Equal(t, encodeAttrs(alignments, int32(10+idx)), rows[idx][0].Raw)
+			assert.Equal(t, shortVarlena(body), rows[idx][1].Raw)
+		}
+	}
+
+	hf, err := ReadHeapFile(path, DefaultBlockSize, alignments)
+	require.NoError(t, err)
+	var rows []Row
+	for _, page := range hf.Pages {
+		for _, tp := range page.Tuples {
+			rows = append(rows, tp.Data)
+		}
+	}
+	check(rows)
+
+	catalog := NewMemCatalog(DefaultBlockSize)
+	catalog.Add("t", MemRelation{Path: path, AttrAligns: alignments})
+	table, err := NewTable(context.Background(), catalog, "t")
+	require.NoError(t, err)
+	rows, err = table.GetTuples()
+	require.NoError(t, err)
+	check(rows)
+}
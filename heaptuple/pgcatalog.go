@@ -0,0 +1,146 @@
+package heaptuple
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// PgxCatalog is a CatalogSource backed by a live server. The server must run
+// on the host whose data directory is read.
+type PgxCatalog struct {
+	conn   *pgx.Conn
+	pgdata string
+}
+
+// ConnectCatalog connects to the server described by dsn.
+func ConnectCatalog(ctx context.Context, dsn string) (*PgxCatalog, error) {
+	config, err := pgx.ParseConfig(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return NewPgxCatalog(ctx, config)
+}
+
+// NewPgxCatalog connects with config. The session shows dates and times in
+// ISO form and UTC, the form attmissingval is parsed in.
+func NewPgxCatalog(ctx context.Context, config *pgx.ConnConfig) (*PgxCatalog, error) {
+	config = config.Copy()
+	if config.RuntimeParams == nil {
+		config.RuntimeParams = make(map[string]string)
+	}
+	config.RuntimeParams["DateStyle"] = "ISO, MDY"
+	config.RuntimeParams["IntervalStyle"] = "postgres"
+	config.RuntimeParams["TimeZone"] = "UTC"
+	conn, err := pgx.ConnectConfig(ctx, config)
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to database: %w", err)
+	}
+
+	var pgdata string
+	row := conn.QueryRow(ctx, "show data_directory;")
+	err = row.Scan(&pgdata)
+	if err != nil {
+		conn.Close(ctx)
+		return nil, err
+	}
+	return &PgxCatalog{conn: conn, pgdata: pgdata}, nil
+}
+
+func (c *PgxCatalog) DataDirectory() string {
+	return c.pgdata
+}
+
+func (c *PgxCatalog) Close(ctx context.Context) error {
+	return c.conn.Close(ctx)
+}
+
+// RelationPath resolves table like a regclass, so it may be schema
+// qualified.
+func (c *PgxCatalog) RelationPath(ctx context.Context, table string) (string, error) {
+	var fpath string
+	row := c.conn.QueryRow(ctx, "SELECT pg_relation_filepath($1::text::regclass)", table)
+	err := row.Scan(&fpath)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(c.pgdata, fpath), nil
+}
+
+// ToastRelation follows reltoastrelid, the toast relation keeps its name when
+// table is rewritten and gets a new relfilenode.
+func (c *PgxCatalog) ToastRelation(ctx context.Context, table string) (string, error) {
+	var toast string
+	row := c.conn.QueryRow(ctx, `
+SELECT CASE WHEN c.reltoastrelid = 0 THEN '' ELSE c.reltoastrelid::regclass::text END
+  FROM pg_class c
+ WHERE c.oid = $1::text::regclass`, table)
+	err := row.Scan(&toast)
+	return toast, err
+}
+
+func (c *PgxCatalog) AttrAligns(ctx context.Context, table string) ([]AttrAlign, error) {
+	return getAlign(ctx, c.conn, table)
+}
+
+func (c *PgxCatalog) BlockSize(ctx context.Context) (int, error) {
+	var blockSize int
+	row := c.conn.QueryRow(ctx, "SELECT current_setting('block_size')::int")
+	err := row.Scan(&blockSize)
+	return blockSize, err
+}
+
+func getAlign(ctx context.Context, conn *pgx.Conn, table string) ([]AttrAlign, error) {
+	var alignSQL = `
+SELECT a.attname, COALESCE(t.typname, ''), a.attalign::text, a.attlen,
+       a.atttypid, a.attbyval, a.atttypmod, a.attisdropped, a.atthasmissing,
+       -- the reg* aliases are shown by name, their oid is what is stored
+       CASE WHEN NOT a.atthasmissing THEN NULL
+            WHEN t.typname = 'regclass' THEN m.val::regclass::oid::text
+            WHEN t.typname = 'regconfig' THEN m.val::regconfig::oid::text
+            WHEN t.typname = 'regdictionary' THEN m.val::regdictionary::oid::text
+            WHEN t.typname = 'regnamespace' THEN m.val::regnamespace::oid::text
+            WHEN t.typname = 'regoper' THEN m.val::regoper::oid::text
+            WHEN t.typname = 'regoperator' THEN m.val::regoperator::oid::text
+            WHEN t.typname = 'regproc' THEN m.val::regproc::oid::text
+            WHEN t.typname = 'regprocedure' THEN m.val::regprocedure::oid::text
+            WHEN t.typname = 'regrole' THEN m.val::regrole::oid::text
+            WHEN t.typname = 'regtype' THEN m.val::regtype::oid::text
+            ELSE m.val END
+  FROM pg_attribute a
+  LEFT JOIN pg_type t ON (t.oid = a.atttypid)
+  CROSS JOIN LATERAL (SELECT (a.attmissingval::text::text[])[1] AS val) m
+ WHERE a.attrelid = $1::text::regclass
+   AND a.attnum > 0
+ ORDER BY a.attnum;
+`
+	rows, err := conn.Query(ctx, alignSQL, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var alignments []AttrAlign
+	for rows.Next() {
+		var (
+			item    AttrAlign
+			missing *string
+		)
+		err = rows.Scan(&item.AttName, &item.TypName, &item.TypAlign, &item.TypLen,
+			&item.TypOid, &item.TypByVal, &item.TypMod, &item.IsDropped, &item.HasMissing, &missing)
+		if err != nil {
+			return nil, err
+		}
+		if missing != nil {
+			item.MissingVal = *missing
+		}
+		alignments = append(alignments, item)
+	}
+	// a query failing midway ends the loop as if it was done
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return alignments, nil
+}
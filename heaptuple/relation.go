@@ -0,0 +1,178 @@
+package heaptuple
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+type ForkNumber int
+
+const (
+	MainForkNum ForkNumber = iota
+	FSMForkNum
+	VisibilityMapForkNum
+	InitForkNum
+)
+
+var forkNames = []string{
+	MainForkNum:          "main",
+	FSMForkNum:           "fsm",
+	VisibilityMapForkNum: "vm",
+	InitForkNum:          "init",
+}
+
+func (f ForkNumber) String() string {
+	if f < 0 || int(f) >= len(forkNames) {
+		return fmt.Sprintf("fork(%d)", int(f))
+	}
+	return forkNames[f]
+}
+
+// suffix is appended to the path of the main fork, e.g. 16384_fsm
+func (f ForkNumber) suffix() string {
+	if f == MainForkNum {
+		return ""
+	}
+	return "_" + f.String()
+}
+
+// RelSegBytes is the size of a full segment file, RELSEG_SIZE blocks.
+const RelSegBytes = 1024 * 1024 * 1024
+
+// Segment is one file of a fork, <relfilenode>[_fork][.segno].
+type Segment struct {
+	Path       string
+	SegNo      int
+	FirstBlock uint32
+	Blocks     uint32
+}
+
+// Relation is the set of files backing a relation: every segment of the
+// main, free space map, visibility map and init forks. Blocks are addressed
+// by (fork, global block number), segments are an implementation detail.
+type Relation struct {
+	Path      string
+	BlockSize int
+	forks     map[ForkNumber][]Segment
+}
+
+// OpenRelation discovers the files of the relation whose main fork starts at
+// path. Only the main fork is required.
+func OpenRelation(path string, blockSize int) (Relation, error) {
+	r := Relation{
+		Path:      path,
+		BlockSize: blockSize,
+		forks:     make(map[ForkNumber][]Segment),
+	}
+	for fork := MainForkNum; fork <= InitForkNum; fork++ {
+		segments, err := r.discover(fork)
+		if err != nil {
+			return Relation{}, err
+		}
+		if len(segments) == 0 {
+			if fork == MainForkNum {
+				return Relation{}, fmt.Errorf("relation %s: %w", path, os.ErrNotExist)
+			}
+			continue
+		}
+		r.forks[fork] = segments
+	}
+	return r, nil
+}
+
+func (r Relation) segBlocks() uint32 {
+	return uint32(RelSegBytes / r.BlockSize)
+}
+
+func (r Relation) discover(fork ForkNumber) ([]Segment, error) {
+	var (
+		segments []Segment
+		next     uint32
+		short    bool
+	)
+	for segNo := 0; ; segNo++ {
+		path := r.Path + fork.suffix()
+		if segNo > 0 {
+			path = fmt.Sprintf("%s.%d", path, segNo)
+		}
+		info, err := os.Stat(path)
+		if os.IsNotExist(err) {
+			return segments, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		// like mdnblocks the relation ends at the first segment that is not
+		// full. mdtruncate leaves the files after it empty rather than
+		// deleting them, data in them means blocks are missing.
+		if short {
+			if info.Size() != 0 {
+				prev := segments[len(segments)-1]
+				return nil, fmt.Errorf("segment %s has %d blocks, expected %d", prev.Path, prev.Blocks, r.segBlocks())
+			}
+			continue
+		}
+		seg := Segment{
+			Path:       path,
+			SegNo:      segNo,
+			FirstBlock: next,
+			Blocks:     uint32((info.Size() + int64(r.BlockSize) - 1) / int64(r.BlockSize)),
+		}
+		segments = append(segments, seg)
+		next += seg.Blocks
+		short = seg.Blocks != r.segBlocks()
+	}
+}
+
+// HasFork reports whether any file of fork exists.
+func (r Relation) HasFork(fork ForkNumber) bool {
+	return len(r.forks[fork]) > 0
+}
+
+func (r Relation) Segments(fork ForkNumber) []Segment {
+	return r.forks[fork]
+}
+
+// NBlocks returns the number of blocks of fork, a trailing partial block
+// counts as one.
+func (r Relation) NBlocks(fork ForkNumber) uint32 {
+	segments := r.forks[fork]
+	if len(segments) == 0 {
+		return 0
+	}
+	last := segments[len(segments)-1]
+	return last.FirstBlock + last.Blocks
+}
+
+// Locate returns the segment holding blkno and the block number inside it.
+func (r Relation) Locate(fork ForkNumber, blkno uint32) (Segment, uint32, error) {
+	segNo := int(blkno / r.segBlocks())
+	segments := r.forks[fork]
+	if segNo >= len(segments) || blkno-segments[segNo].FirstBlock >= segments[segNo].Blocks {
+		return Segment{}, 0, fmt.Errorf("relation %s: block %d of %s fork out of range, %d blocks",
+			r.Path, blkno, fork, r.NBlocks(fork))
+	}
+	return segments[segNo], blkno - segments[segNo].FirstBlock, nil
+}
+
+// ReadBlock returns the raw content of block blkno of fork. A trailing
+// partial block is returned as is.
+func (r Relation) ReadBlock(fork ForkNumber, blkno uint32) ([]byte, error) {
+	seg, local, err := r.Locate(fork, blkno)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(seg.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	buf := make([]byte, r.BlockSize)
+	n, err := f.ReadAt(buf, int64(local)*int64(r.BlockSize))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return buf[:n], nil
+}
@@ -0,0 +1,74 @@
+package heaptuple
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRelationSegmentsAndForks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "16384")
+	// a sparse full first segment
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	require.NoError(t, f.Truncate(RelSegBytes))
+	require.NoError(t, f.Close())
+
+	second := make([]byte, 2*DefaultBlockSize)
+	second[DefaultBlockSize] = 0xAB
+	require.NoError(t, os.WriteFile(path+".1", second, 0o644))
+	require.NoError(t, os.WriteFile(path+"_fsm", make([]byte, 3*DefaultBlockSize), 0o644))
+	require.NoError(t, os.WriteFile(path+"_vm", make([]byte, DefaultBlockSize), 0o644))
+
+	rel, err := OpenRelation(path, DefaultBlockSize)
+	require.NoError(t, err)
+
+	segBlocks := uint32(RelSegBytes / DefaultBlockSize)
+	assert.Len(t, rel.Segments(MainForkNum), 2)
+	assert.Equal(t, segBlocks+2, rel.NBlocks(MainForkNum))
+	assert.EqualValues(t, 3, rel.NBlocks(FSMForkNum))
+	assert.EqualValues(t, 1, rel.NBlocks(VisibilityMapForkNum))
+	assert.False(t, rel.HasFork(InitForkNum))
+
+	seg, local, err := rel.Locate(MainForkNum, segBlocks+1)
+	require.NoError(t, err)
+	assert.Equal(t, path+".1", seg.Path)
+	assert.EqualValues(t, 1, local)
+
+	block, err := rel.ReadBlock(MainForkNum, segBlocks+1)
+	require.NoError(t, err)
+	assert.EqualValues(t, 0xAB, block[0])
+
+	_, err = rel.ReadBlock(MainForkNum, segBlocks+2)
+	assert.Error(t, err)
+	_, err = rel.ReadBlock(InitForkNum, 0)
+	assert.Error(t, err)
+}
+
+func TestRelationShortMiddleSegment(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "16384")
+	require.NoError(t, os.WriteFile(path, make([]byte, DefaultBlockSize), 0o644))
+	require.NoError(t, os.WriteFile(path+".1", make([]byte, DefaultBlockSize), 0o644))
+
+	_, err := OpenRelation(path, DefaultBlockSize)
+	assert.Error(t, err)
+}
+
+func TestRelationTruncatedSegments(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "16384")
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	require.NoError(t, f.Truncate(RelSegBytes))
+	require.NoError(t, f.Close())
+	require.NoError(t, os.WriteFile(path+".1", make([]byte, DefaultBlockSize), 0o644))
+	// left empty by mdtruncate
+	require.NoError(t, os.WriteFile(path+".2", nil, 0o644))
+
+	rel, err := OpenRelation(path, DefaultBlockSize)
+	require.NoError(t, err)
+	assert.Len(t, rel.Segments(MainForkNum), 2)
+	assert.Equal(t, uint32(RelSegBytes/DefaultBlockSize)+1, rel.NBlocks(MainForkNum))
+}
@@ -0,0 +1,68 @@
+package heaptuple
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+// DamagedItem is a page or a tuple quarantined by a salvage read.
+type DamagedItem struct {
+	Path  string `json:"path"`
+	Block uint32 `json:"block"`
+	// LinePointer is 0 when the whole page was skipped.
+	LinePointer uint16 `json:"line_pointer,omitempty"`
+	Attribute   string `json:"attribute,omitempty"`
+	Reason      string `json:"reason"`
+	// Bytes holds the raw page or tuple, hex encoded.
+	Bytes string `json:"bytes"`
+}
+
+func newDamagedItem(path string, blkno uint32, err error, raw []byte) DamagedItem {
+	item := DamagedItem{Path: path, Block: blkno, Reason: err.Error(), Bytes: hex.EncodeToString(raw)}
+	var ce *CorruptionError
+	if errors.As(err, &ce) {
+		item.LinePointer, item.Attribute = ce.LinePointer, ce.Attribute
+	}
+	return item
+}
+
+// tupleBytes returns the storage of slot, clipped to the page.
+func tupleBytes(page []byte, slot SlotID) []byte {
+	start := int(slot.GetTupleOffset())
+	end := start + int(slot.GetTupleLength())
+	if start > len(page) {
+		return nil
+	}
+	if end > len(page) {
+		end = len(page)
+	}
+	return page[start:end]
+}
+
+// DamageReport lists everything a salvage read of a relation skipped.
+type DamageReport struct {
+	Relation string        `json:"relation"`
+	Items    []DamagedItem `json:"items"`
+}
+
+func WriteDamageReport(w io.Writer, r DamageReport) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// Salvage reads every tuple that can still be read. Damaged pages and
+// tuples, and rows whose toasted values cannot be rebuilt, are left out and
+// listed in the report. Only I/O errors fail.
+func (t Table) Salvage() ([]Row, DamageReport, error) {
+	var ret []Row
+	s := t.ScanWithOptions(ReadOptions{Salvage: true})
+	defer s.Close()
+	for s.Next() {
+		ret = append(ret, s.Row())
+	}
+	report := DamageReport{Relation: t.self.Path, Items: s.Damage()}
+	return ret, report, s.Err()
+}
@@ -0,0 +1,86 @@
+package heaptuple
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSalvage(t *testing.T) {
+	alignments := []AttrAlign{
+		{AttName: "id", TypName: "int4", TypAlign: "i", TypLen: 4},
+		{AttName: "body", TypName: "text", TypAlign: "i", TypLen: -1},
+	}
+	tuple := func(id int32, body []byte) []byte {
+		return buildTuple(testTuple{xmin: 3, nulls: []bool{false, false}, data: append(encodeAttrs(alignments, id), body...)})
+	}
+	broken := tuple(2, []byte{100<<1 | 1, 'x'})
+
+	good := buildPage(DefaultBlockSize, tuple(1, shortVarlena("a")), broken)
+	overlapping := buildPage(DefaultBlockSize, tuple(3, shortVarlena("c")), tuple(4, shortVarlena("d")))
+	setSlot(overlapping, 1, LP_NORMAL, DefaultBlockSize-32+8, 23)
+	inverted := buildPage(DefaultBlockSize, tuple(5, shortVarlena("e")))
+	binary.LittleEndian.PutUint16(inverted[12:], DefaultBlockSize-8)
+	last := buildPage(DefaultBlockSize, tuple(6, shortVarlena("f")))
+
+	var file []byte
+	for _, page := range [][]byte{good, overlapping, inverted, last} {
+		file = append(file, page...)
+	}
+	file = append(file, last[:100]...)
+	path := filepath.Join(t.TempDir(), "16384")
+	require.NoError(t, os.WriteFile(path, file, 0o644))
+
+	// without salvage the first damaged page stops the read
+	hf, err := ReadHeapFile(path, DefaultBlockSize, alignments)
+	assert.ErrorIs(t, err, ErrCorruptPage)
+	assert.Len(t, hf.Pages, 1)
+
+	hf, err = ReadHeapFileWithOptions(path, DefaultBlockSize, alignments, ReadOptions{Salvage: true})
+	require.NoError(t, err)
+	assert.Len(t, hf.Pages, 5)
+	require.Len(t, hf.Damage, 4)
+
+	catalog := NewMemCatalog(DefaultBlockSize)
+	catalog.Add("t", MemRelation{Path: path, AttrAligns: alignments})
+	table, err := NewTable(context.Background(), catalog, "t")
+	require.NoError(t, err)
+	rows, report, err := table.Salvage()
+	require.NoError(t, err)
+	assert.Equal(t, []map[string]string{{"id": "1", "body": "a"}, {"id": "6", "body": "f"}}, rowStrings(rows))
+
+	require.Len(t, report.Items, 4)
+	assert.EqualValues(t, 0, report.Items[0].Block)
+	assert.EqualValues(t, 2, report.Items[0].LinePointer)
+	assert.Equal(t, "body", report.Items[0].Attribute)
+	assert.Equal(t, hex.EncodeToString(broken), report.Items[0].Bytes)
+	assert.EqualValues(t, 1, report.Items[1].Block)
+	assert.Equal(t, hex.EncodeToString(overlapping), report.Items[1].Bytes)
+	assert.EqualValues(t, 2, report.Items[2].Block)
+	assert.EqualValues(t, 4, report.Items[3].Block)
+	assert.Len(t, report.Items[3].Bytes, 200)
+
+	// a row whose toast value is gone is skipped with its bytes
+	toasted := newToastedTable(t)
+	rows, toastReport, err := toasted.Salvage()
+	require.NoError(t, err)
+	assert.Len(t, rows, 2)
+	require.Len(t, toastReport.Items, 1)
+	assert.EqualValues(t, 1, toastReport.Items[0].Block)
+	assert.EqualValues(t, 1, toastReport.Items[0].LinePointer)
+	assert.Equal(t, hex.EncodeToString(tuple(3, toastPointer(901, 3))), toastReport.Items[0].Bytes)
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteDamageReport(&buf, report))
+	var decoded DamageReport
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+	assert.Equal(t, report, decoded)
+}
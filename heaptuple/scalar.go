@@ -0,0 +1,184 @@
+package heaptuple
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// fixedDecoder decodes a fixed width type stored in length bytes.
+type fixedDecoder struct {
+	length int
+	decode func(data []byte) interface{}
+}
+
+var (
+	decodeUint32 = fixedDecoder{4, func(data []byte) interface{} { return binary.LittleEndian.Uint32(data) }}
+
+	fixedDecoders = map[string]fixedDecoder{
+		"bool": {1, func(data []byte) interface{} { return data[0] != 0 }},
+		"char": {1, func(data []byte) interface{} { return data[0] }},
+		"int2": {2, func(data []byte) interface{} { return int16(binary.LittleEndian.Uint16(data)) }},
+		"int4": {4, func(data []byte) interface{} { return int32(binary.LittleEndian.Uint32(data)) }},
+		"int8": {8, func(data []byte) interface{} { return int64(binary.LittleEndian.Uint64(data)) }},
+		"float4": {4, func(data []byte) interface{} {
+			return math.Float32frombits(binary.LittleEndian.Uint32(data))
+		}},
+		"float8": {8, func(data []byte) interface{} {
+			return math.Float64frombits(binary.LittleEndian.Uint64(data))
+		}},
+		"oid": decodeUint32,
+		// the OID alias types, shown as the number since the names they
+		// stand for are not known offline
+		"regclass":      decodeUint32,
+		"regcollation":  decodeUint32,
+		"regconfig":     decodeUint32,
+		"regdictionary": decodeUint32,
+		"regnamespace":  decodeUint32,
+		"regoper":       decodeUint32,
+		"regoperator":   decodeUint32,
+		"regproc":       decodeUint32,
+		"regprocedure":  decodeUint32,
+		"regrole":       decodeUint32,
+		"regtype":       decodeUint32,
+		"xid":           decodeUint32,
+		"cid":           decodeUint32,
+		"xid8":          {8, func(data []byte) interface{} { return binary.LittleEndian.Uint64(data) }},
+		"tid": {6, func(data []byte) interface{} {
+			var bins [6]byte
+			copy(bins[:], data)
+			return ParseItemPointer(bins)
+		}},
+		"pg_lsn":      {8, func(data []byte) interface{} { return XLogRecPtr(binary.LittleEndian.Uint64(data)) }},
+		"date":        {4, decodeDate},
+		"time":        {8, decodeTime},
+		"timetz":      {12, decodeTimeTZ},
+		"timestamp":   {8, decodeTimestamp},
+		"timestamptz": {8, decodeTimestamp},
+		"interval":    {16, decodeInterval},
+	}
+)
+
+// decodeFixed decodes the bytes of a fixed width attribute, or of a cstring.
+func decodeFixed(item AttrAlign, data []byte) (interface{}, error) {
+	if decoder, ok := fixedDecoders[item.TypName]; ok {
+		if len(data) != decoder.length {
+			return nil, fmt.Errorf("%w: %s of %d bytes", ErrCorruptTuple, item.TypName, len(data))
+		}
+		return decoder.decode(data), nil
+	}
+	switch item.TypName {
+	case "name":
+		if end := bytes.IndexByte(data, 0); end >= 0 {
+			data = data[:end]
+		}
+		return string(data), nil
+	case "cstring":
+		return string(data[:len(data)-1]), nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnsupportedType, item.TypName)
+}
+
+// parseScalar parses the text form of the scalar types of fixedDecoders into
+// the value they decode to, ok is false for other types.
+func parseScalar(typName, text string) (value interface{}, ok bool, err error) {
+	switch typName {
+	case "bool":
+		switch text {
+		case "t", "true":
+			return true, true, nil
+		case "f", "false":
+			return false, true, nil
+		}
+		return nil, true, fmt.Errorf("invalid bool %q", text)
+	case "char":
+		// charin, \ooo as written by formatChar
+		if len(text) == 4 && text[0] == '\\' && strings.Trim(text[1:], "01234567") == "" {
+			v, _ := strconv.ParseUint(text[1:], 8, 16)
+			return byte(v), true, nil
+		}
+		if text == "" {
+			return byte(0), true, nil
+		}
+		return text[0], true, nil
+	case "int2":
+		v, err := strconv.ParseInt(text, 10, 16)
+		return int16(v), true, err
+	case "int4":
+		v, err := strconv.ParseInt(text, 10, 32)
+		return int32(v), true, err
+	case "int8":
+		v, err := strconv.ParseInt(text, 10, 64)
+		return v, true, err
+	case "float4":
+		v, err := strconv.ParseFloat(text, 32)
+		return float32(v), true, err
+	case "float8":
+		v, err := strconv.ParseFloat(text, 64)
+		return v, true, err
+	case "xid8":
+		v, err := strconv.ParseUint(text, 10, 64)
+		return v, true, err
+	case "tid":
+		var ip ItemPointer
+		if _, err := fmt.Sscanf(text, "(%d,%d)", &ip.Block, &ip.Offset); err != nil {
+			return nil, true, fmt.Errorf("invalid tid %q", text)
+		}
+		return ip, true, nil
+	case "pg_lsn":
+		var hi, lo uint32
+		if _, err := fmt.Sscanf(text, "%X/%X", &hi, &lo); err != nil {
+			return nil, true, fmt.Errorf("invalid pg_lsn %q", text)
+		}
+		return XLogRecPtr(uint64(hi)<<32 | uint64(lo)), true, nil
+	case "oid", "xid", "cid", "regclass", "regcollation", "regconfig", "regdictionary", "regnamespace",
+		"regoper", "regoperator", "regproc", "regprocedure", "regrole", "regtype":
+		// a reg* alias shows InvalidOid as -, getAlign asks the server for
+		// the number of the others
+		if text == "-" {
+			return uint32(0), true, nil
+		}
+		v, err := strconv.ParseUint(text, 10, 32)
+		return uint32(v), true, err
+	}
+	return nil, false, nil
+}
+
+// formatChar is charout: the zero byte shows as nothing and the bytes
+// outside ASCII in octal, \ooo.
+func formatChar(c byte) string {
+	switch {
+	case c == 0:
+		return ""
+	case c >= 0x80:
+		return fmt.Sprintf(`\%03o`, c)
+	}
+	return string([]byte{c})
+}
+
+// formatFloat is float4out and float8out, the shortest text that reads back
+// the same, in exponent form below 1e-4 and from 1e6 for float4, 1e15 for
+// float8.
+func formatFloat(v float64, bitSize int) string {
+	switch {
+	case math.IsNaN(v):
+		return "NaN"
+	case math.IsInf(v, 1):
+		return "Infinity"
+	case math.IsInf(v, -1):
+		return "-Infinity"
+	}
+	s := strconv.FormatFloat(v, 'e', -1, bitSize)
+	exp, _ := strconv.Atoi(s[strings.IndexByte(s, 'e')+1:])
+	digits := 15
+	if bitSize == 32 {
+		digits = 6
+	}
+	if exp < -4 || exp >= digits {
+		return s
+	}
+	return strconv.FormatFloat(v, 'f', -1, bitSize)
+}
@@ -0,0 +1,75 @@
+package heaptuple
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScalarTypes(t *testing.T) {
+	alignments := []AttrAlign{
+		{AttName: "b", TypName: "bool", TypAlign: "c", TypLen: 1, TypByVal: true},
+		{AttName: "c", TypName: "char", TypAlign: "c", TypLen: 1, TypByVal: true},
+		{AttName: "i2", TypName: "int2", TypAlign: "s", TypLen: 2, TypByVal: true},
+		{AttName: "i8", TypName: "int8", TypAlign: "d", TypLen: 8, TypByVal: true},
+		{AttName: "f4", TypName: "float4", TypAlign: "i", TypLen: 4, TypByVal: true},
+		{AttName: "f8", TypName: "float8", TypAlign: "d", TypLen: 8, TypByVal: true},
+		{AttName: "big", TypName: "float8", TypAlign: "d", TypLen: 8, TypByVal: true},
+		{AttName: "inf", TypName: "float8", TypAlign: "d", TypLen: 8, TypByVal: true},
+		{AttName: "rel", TypName: "regclass", TypAlign: "i", TypLen: 4, TypByVal: true},
+		{AttName: "cmin", TypName: "cid", TypAlign: "i", TypLen: 4, TypByVal: true},
+		{AttName: "ctid", TypName: "tid", TypAlign: "s", TypLen: 6},
+		{AttName: "lsn", TypName: "pg_lsn", TypAlign: "d", TypLen: 8, TypByVal: true},
+	}
+	data := encodeAttrs(alignments, true, []byte{'r'}, int16(-3), int64(-1)<<40, float32(1e6), 0.1,
+		1234567.5, math.Inf(-1), uint32(1259), uint32(2), []byte{0, 0, 7, 0, 3, 0}, uint64(0x16B374D48))
+	th := TupleHeader{Infomask2: uint16(len(alignments))}
+	row, err := ParseTupleData(alignments, &th, data)
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]string{
+		"b": "t", "c": "r", "i2": "-3", "i8": "-1099511627776", "f4": "1e+06", "f8": "0.1",
+		"big": "1234567.5", "inf": "-Infinity", "rel": "1259", "cmin": "2", "ctid": "(7,3)", "lsn": "1/6B374D48",
+	}, row.Strings())
+	assert.Equal(t, int64(-1)<<40, row[3].Value)
+	assert.Equal(t, ItemPointer{Block: 7, Offset: 3}, row[10].Value)
+	assert.Equal(t, XLogRecPtr(0x16B374D48), row[11].Value)
+
+	// attmissingval in text form decodes to the same values
+	for idx, d := range row {
+		value, err := datumFromText(alignments[idx], d.String())
+		require.NoError(t, err, d.Name)
+		assert.Equal(t, d.Value, value, d.Name)
+	}
+	missing := []AttrAlign{
+		{AttName: "ctid", TypName: "tid", TypAlign: "s", TypLen: 6, HasMissing: true, MissingVal: "(7,3)"},
+		{AttName: "lsn", TypName: "pg_lsn", TypAlign: "d", TypLen: 8, HasMissing: true, MissingVal: "1/6B374D48"},
+		{AttName: "rel", TypName: "regclass", TypAlign: "i", TypLen: 4, HasMissing: true, MissingVal: "-"},
+	}
+	row, err = ParseTupleData(missing, &TupleHeader{}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, ItemPointer{Block: 7, Offset: 3}, row[0].Value)
+	assert.Equal(t, XLogRecPtr(0x16B374D48), row[1].Value)
+	assert.Equal(t, uint32(0), row[2].Value)
+
+	// "char" is shown like charout and read back like charin
+	chars := []AttrAlign{
+		{AttName: "zero", TypName: "char", TypAlign: "c", TypLen: 1, TypByVal: true},
+		{AttName: "high", TypName: "char", TypAlign: "c", TypLen: 1, TypByVal: true},
+		{AttName: "slash", TypName: "char", TypAlign: "c", TypLen: 1, TypByVal: true},
+	}
+	row, err = ParseTupleData(chars, &TupleHeader{Infomask2: 3}, []byte{0, 0xE9, '\\'})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"zero": "", "high": `\351`, "slash": `\`}, row.Strings())
+	for idx, d := range row {
+		value, err := datumFromText(chars[idx], d.String())
+		require.NoError(t, err, d.Name)
+		assert.Equal(t, d.Value, value, d.Name)
+	}
+
+	_, err = ParseTupleData([]AttrAlign{{AttName: "i8", TypName: "int8", TypAlign: "d", TypLen: 4}},
+		&TupleHeader{Infomask2: 1}, make([]byte, 4))
+	assert.ErrorIs(t, err, ErrCorruptTuple)
+}
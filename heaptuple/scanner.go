@@ -0,0 +1,188 @@
+package heaptuple
+
+// Scanner walks the tuples of a table lazily, relation then page then line
+// pointer. Only the current page is decoded and toasted values are fetched
+// when their row is reached. Tuples that cannot be deformed are skipped, see
+// Skipped.
+//
+//	s := table.Scan()
+//	defer s.Close()
+//	for s.Next() {
+//		row := s.Row()
+//	}
+//	if err := s.Err(); err != nil {
+//	}
+type Scanner struct {
+	t      Table
+	pages  *RelationPages
+	blkno  uint32
+	page   Page
+	idx    int
+	row    Row
+	header TupleHeader
+	err    error
+
+	skipped []error
+	opts    ReadOptions
+	damage  []DamagedItem
+	toast   *toastReader
+}
+
+// Scan walks the tuples of the table. Unless the table has a Visibility,
+// every tuple on disk is returned, dead ones included.
+func (t Table) Scan() *Scanner {
+	return t.ScanWithOptions(ReadOptions{})
+}
+
+// ScanWithOptions is Scan decoding pages with opts. With the Salvage option a
+// row whose toasted values cannot be read is skipped too, see Damage.
+func (t Table) ScanWithOptions(opts ReadOptions) *Scanner {
+	pages := t.self.Pages(MainForkNum, t.selfAttrAlign)
+	pages.SetOptions(opts)
+	s := &Scanner{
+		t:     t,
+		pages: pages,
+		opts:  opts,
+		toast: t.newToastReader(),
+	}
+	if t.commitTs != nil {
+		s.err = t.checkCommitTsColumns()
+	}
+	return s
+}
+
+// Next advances to the next tuple, it returns false at the end of the table
+// or on error.
+func (s *Scanner) Next() bool {
+	if s.err != nil {
+		return false
+	}
+	for {
+		for s.idx >= len(s.page.Tuples) {
+			if !s.pages.Next() {
+				s.err = s.pages.Err()
+				return false
+			}
+			s.blkno = s.pages.BlockNumber()
+			s.page = s.pages.Page()
+			s.idx = 0
+		}
+		s.idx++
+		if !s.page.IsNormal(s.idx - 1) {
+			continue
+		}
+		if err := s.page.Tuples[s.idx-1].Err; err != nil {
+			s.skipped = append(s.skipped, err)
+			continue
+		}
+
+		tp := s.page.Tuples[s.idx-1]
+		if s.t.vis != nil {
+			visible, err := s.t.vis.TupleVisible(tp.Header)
+			if err != nil {
+				s.err = err
+				return false
+			}
+			if !visible {
+				continue
+			}
+		}
+		err := s.t.detoast(tp.Data, s.toast)
+		if err != nil && s.opts.Salvage {
+			s.damage = append(s.damage, s.damagedTuple(err))
+			continue
+		}
+		if err == nil && s.t.commitTs != nil {
+			tp.Data, err = s.t.addCommitTimestamps(tp.Data, tp.Header)
+		}
+		if err != nil {
+			s.err = err
+			return false
+		}
+		s.row = tp.Data
+		s.header = tp.Header
+		return true
+	}
+}
+
+// damagedTuple records the current tuple as skipped for err, with its bytes
+// read again from the block.
+func (s *Scanner) damagedTuple(err error) DamagedItem {
+	var raw []byte
+	if block, readErr := s.t.self.ReadBlock(MainForkNum, s.blkno); readErr == nil {
+		raw = tupleBytes(block, s.page.Slots[s.idx-1])
+	}
+	item := newDamagedItem(s.t.self.Path, s.blkno, err, raw)
+	item.LinePointer = uint16(s.idx)
+	return item
+}
+
+// Row returns the tuple reached by the last call to Next.
+func (s *Scanner) Row() Row {
+	return s.row
+}
+
+// BlockNumber returns the block holding Row.
+func (s *Scanner) BlockNumber() uint32 {
+	return s.blkno
+}
+
+// ItemPointer returns the location of Row, the start of its update chain
+// for VersionChain.
+func (s *Scanner) ItemPointer() ItemPointer {
+	return ItemPointer{Block: s.blkno, Offset: uint16(s.idx)}
+}
+
+// XmaxInfo is the DescribeXmax of Row with the Visibility of the table.
+func (s *Scanner) XmaxInfo() (string, error) {
+	return DescribeXmax(s.header, s.t.vis)
+}
+
+func (s *Scanner) Err() error {
+	return s.err
+}
+
+// Damage returns what the Salvage option skipped so far.
+func (s *Scanner) Damage() []DamagedItem {
+	return append(append([]DamagedItem(nil), s.pages.Damage()...), s.damage...)
+}
+
+// Skipped returns the errors of the damaged tuples passed over so far.
+func (s *Scanner) Skipped() []error {
+	return s.skipped
+}
+
+// Close releases the files of the scan, it may be called before Next returns
+// false to stop early.
+func (s *Scanner) Close() error {
+	return s.pages.Close()
+}
+
+// Rows returns the scan as an iterator function, yield is called with each
+// row and the scan stops when it returns false:
+//
+//	table.Rows()(func(row Row, err error) bool {
+//		return err == nil
+//	})
+//
+// The errors of the skipped tuples, then the error of the scan if any, are
+// yielded last.
+func (t Table) Rows() func(yield func(Row, error) bool) {
+	return func(yield func(Row, error) bool) {
+		s := t.Scan()
+		defer s.Close()
+		for s.Next() {
+			if !yield(s.Row(), nil) {
+				return
+			}
+		}
+		for _, err := range s.Skipped() {
+			if !yield(nil, err) {
+				return
+			}
+		}
+		if err := s.Err(); err != nil {
+			yield(nil, err)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package heaptuple
+
+import (
+	"context"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// shortVarlena prefixes data with a 1 byte varlena header.
+func shortVarlena(data string) []byte {
+	return append([]byte{byte((len(data)+1)<<1 | 1)}, data...)
+}
+
+// toastPointer is an on disk varattrib_1b_e pointing at valueID.
+func toastPointer(valueID uint32, size int) []byte {
+	ret := make([]byte, 18)
+	ret[0], ret[1] = 0x01, VARTAG_ONDISK
+	binary.LittleEndian.PutUint32(ret[2:], uint32(size+4))
+	binary.LittleEndian.PutUint32(ret[6:], uint32(size))
+	binary.LittleEndian.PutUint32(ret[10:], valueID)
+	return ret
+}
+
+func newToastedTable(t *testing.T) Table {
+	dir := t.TempDir()
+	selfAttrAlign := []AttrAlign{
+		{AttName: "id", TypName: "int4", TypAlign: "i", TypLen: 4},
+		{AttName: "body", TypName: "text", TypAlign: "i", TypLen: -1},
+	}
+	toastAttrAlign := []AttrAlign{
+		{AttName: "chunk_id", TypName: "oid", TypAlign: "i", TypLen: 4},
+		{AttName: "chunk_seq", TypName: "int4", TypAlign: "i", TypLen: 4},
+		{AttName: "chunk_data", TypName: "bytea", TypAlign: "i", TypLen: -1},
+	}
+	row := func(id int32, body []byte) []byte {
+		return buildTuple(testTuple{xmin: 3, nulls: []bool{false, false}, data: append(encodeAttrs(selfAttrAlign, id), body...)})
+	}
+	chunk := func(valueID uint32, seq int32, data string) []byte {
+		return buildTuple(testTuple{xmin: 3, nulls: []bool{false, false, false},
+			data: append(encodeAttrs(toastAttrAlign, valueID, seq), shortVarlena(data)...)})
+	}
+
+	selfPath, toastPath := filepath.Join(dir, "16384"), filepath.Join(dir, "16387")
+	require.NoError(t, os.WriteFile(selfPath, append(
+		buildPage(DefaultBlockSize, row(1, shortVarlena("inline")), row(2, toastPointer(900, 11))),
+		buildPage(DefaultBlockSize, row(3, toastPointer(901, 3)))...,
+	), 0o644))
+	require.NoError(t, os.WriteFile(toastPath, buildPage(DefaultBlockSize,
+		chunk(900, 1, "world"), chunk(900, 0, "hello, "),
+	), 0o644))
+
+	catalog := NewMemCatalog(DefaultBlockSize)
+	catalog.Add("t", MemRelation{Path: selfPath, Toast: "pg_toast_16384", AttrAligns: selfAttrAlign})
+	catalog.Add("pg_toast_16384", MemRelation{Path: toastPath, AttrAligns: toastAttrAlign})
+	table, err := NewTable(context.Background(), catalog, "t")
+	require.NoError(t, err)
+	return table
+}
+
+func TestScanner(t *testing.T) {
+	table := newToastedTable(t)
+
+	s := table.Scan()
+	defer s.Close()
+	require.True(t, s.Next())
+	assert.Equal(t, map[string]string{"id": "1", "body": "inline"}, s.Row().Strings())
+	require.True(t, s.Next())
+	assert.Equal(t, map[string]string{"id": "2", "body": "hello, world"}, s.Row().Strings())
+	assert.EqualValues(t, 0, s.BlockNumber())
+	// the chunks are indexed once, on the first toasted value
+	assert.Equal(t, map[uint32][]ItemPointer{900: {{Block: 0, Offset: 1}, {Block: 0, Offset: 2}}}, s.toast.chunks)
+
+	// value 901 has no chunk
+	assert.False(t, s.Next())
+	assert.Error(t, s.Err())
+	assert.False(t, s.Next())
+}
+
+func TestRowsEarlyTermination(t *testing.T) {
+	table := newToastedTable(t)
+
+	var ids []string
+	table.Rows()(func(row Row, err error) bool {
+		require.NoError(t, err)
+		ids = append(ids, row.Strings()["id"])
+		return len(ids) < 2
+	})
+	assert.Equal(t, []string{"1", "2"}, ids)
+
+	_, err := table.GetTuples()
+	assert.Error(t, err)
+}
@@ -0,0 +1,110 @@
+package heaptuple
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// SchemaVersion is the version of the schema file format written by
+// WriteSchema. Files of any other version are rejected.
+const SchemaVersion = 1
+
+// Schema describes the layout of a relation and of its toast relation, it is
+// shipped next to the heap files so they can be decoded without a server.
+type Schema struct {
+	Version    int         `json:"version"`
+	Relation   string      `json:"relation"`
+	BlockSize  int         `json:"block_size"`
+	Attributes []AttrAlign `json:"attributes"`
+	Toast      *Schema     `json:"toast,omitempty"`
+}
+
+// DumpSchema describes table and its toast relation as src sees them.
+func DumpSchema(ctx context.Context, src CatalogSource, table string) (s Schema, err error) {
+	s.Version = SchemaVersion
+	s.Relation = table
+	s.BlockSize, err = src.BlockSize(ctx)
+	if err != nil {
+		return
+	}
+	s.Attributes, err = src.AttrAligns(ctx, table)
+	if err != nil {
+		return
+	}
+	toast, err := src.ToastRelation(ctx, table)
+	if err != nil || toast == "" {
+		return
+	}
+	toastSchema, err := DumpSchema(ctx, src, toast)
+	if err != nil {
+		return
+	}
+	s.Toast = &toastSchema
+	return
+}
+
+func WriteSchema(w io.Writer, s Schema) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(s)
+}
+
+func ReadSchema(r io.Reader) (s Schema, err error) {
+	err = json.NewDecoder(r).Decode(&s)
+	if err != nil {
+		return
+	}
+	err = s.validate()
+	return
+}
+
+func LoadSchema(path string) (Schema, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Schema{}, err
+	}
+	defer f.Close()
+	return ReadSchema(f)
+}
+
+func (s Schema) validate() error {
+	if s.Version != SchemaVersion {
+		return fmt.Errorf("schema %q: unsupported version %d, expected %d", s.Relation, s.Version, SchemaVersion)
+	}
+	if s.Relation == "" {
+		return fmt.Errorf("schema: relation name is missing")
+	}
+	if s.BlockSize <= 0 {
+		return fmt.Errorf("schema %q: invalid block size %d", s.Relation, s.BlockSize)
+	}
+	for _, attr := range s.Attributes {
+		if attr.TypLen == 0 || attr.TypLen < -2 {
+			return fmt.Errorf("schema %q: attribute %q has invalid typlen %d", s.Relation, attr.AttName, attr.TypLen)
+		}
+		switch attr.TypAlign {
+		case "c", "s", "i", "d":
+		default:
+			return fmt.Errorf("schema %q: attribute %q has invalid typalign %q", s.Relation, attr.AttName, attr.TypAlign)
+		}
+	}
+	if s.Toast != nil {
+		return s.Toast.validate()
+	}
+	return nil
+}
+
+// Catalog returns a CatalogSource describing the relation of the schema
+// stored at selfPath and its toast relation stored at toastPath.
+func (s Schema) Catalog(selfPath, toastPath string) *MemCatalog {
+	c := NewMemCatalog(s.BlockSize)
+	self := MemRelation{Path: selfPath, AttrAligns: s.Attributes}
+	if s.Toast != nil {
+		self.Toast = s.Toast.Relation
+		c.Add(s.Toast.Relation, MemRelation{Path: toastPath, AttrAligns: s.Toast.Attributes})
+	}
+	c.Add(s.Relation, self)
+	return c
+}
@@ -0,0 +1,56 @@
+package heaptuple
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchemaRoundTrip(t *testing.T) {
+	src := NewMemCatalog(DefaultBlockSize)
+	src.Add("t", MemRelation{
+		Path:  "/data/base/5/16384",
+		Toast: "pg_toast_16384",
+		AttrAligns: []AttrAlign{
+			{AttName: "id", TypName: "int4", TypAlign: "i", TypLen: 4, TypOid: 23, TypByVal: true, TypMod: -1},
+			{AttName: "note", TypName: "text", TypAlign: "i", TypLen: -1, TypOid: 25, TypMod: -1, HasMissing: true, MissingVal: "none"},
+		},
+	})
+	src.Add("pg_toast_16384", MemRelation{
+		Path: "/data/base/5/16387",
+		AttrAligns: []AttrAlign{
+			{AttName: "chunk_id", TypName: "oid", TypAlign: "i", TypLen: 4, TypOid: 26, TypByVal: true, TypMod: -1},
+			{AttName: "chunk_seq", TypName: "int4", TypAlign: "i", TypLen: 4, TypOid: 23, TypByVal: true, TypMod: -1},
+			{AttName: "chunk_data", TypName: "bytea", TypAlign: "i", TypLen: -1, TypOid: 17, TypMod: -1},
+		},
+	})
+
+	ctx := context.Background()
+	schema, err := DumpSchema(ctx, src, "t")
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteSchema(&buf, schema))
+	loaded, err := ReadSchema(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, schema, loaded)
+
+	catalog := loaded.Catalog("/tmp/16384", "/tmp/16387")
+	toast, err := catalog.ToastRelation(ctx, "t")
+	require.NoError(t, err)
+	assert.Equal(t, "pg_toast_16384", toast)
+	toastAttrs, err := catalog.AttrAligns(ctx, toast)
+	require.NoError(t, err)
+	assert.Equal(t, schema.Toast.Attributes, toastAttrs)
+	path, err := catalog.RelationPath(ctx, toast)
+	require.NoError(t, err)
+	assert.Equal(t, "/tmp/16387", path)
+}
+
+func TestSchemaVersion(t *testing.T) {
+	_, err := ReadSchema(bytes.NewBufferString(`{"version": 2, "relation": "t", "block_size": 8192}`))
+	assert.Error(t, err)
+}
@@ -0,0 +1,68 @@
+package heaptuple
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLinePointerFlags(t *testing.T) {
+	alignments := []AttrAlign{{AttName: "id", TypName: "int4", TypAlign: "i", TypLen: 4}}
+	tuple := func(id int32) []byte {
+		return buildTuple(testTuple{xmin: 3, nulls: []bool{false}, data: encodeAttrs(alignments, id)})
+	}
+	page := buildPage(DefaultBlockSize, tuple(1), tuple(2), tuple(3), tuple(4), tuple(5))
+	deadOffset := uint16(DefaultBlockSize - 4*32)
+	setSlot(page, 1, LP_REDIRECT, 1, 0)
+	setSlot(page, 2, LP_UNUSED, 0, 0)
+	setSlot(page, 3, LP_DEAD, deadOffset, 28)
+	setSlot(page, 4, LP_DEAD, 0, 0)
+
+	p, err := ReadPage(page, alignments)
+	require.NoError(t, err)
+	require.Len(t, p.Slots, 5)
+	assert.Equal(t, []LPFLAG{LP_NORMAL, LP_REDIRECT, LP_UNUSED, LP_DEAD, LP_DEAD}, []LPFLAG{
+		p.Slots[0].GetFlags(), p.Slots[1].GetFlags(), p.Slots[2].GetFlags(), p.Slots[3].GetFlags(), p.Slots[4].GetFlags(),
+	})
+	assert.EqualValues(t, 1, p.Slots[1].GetRedirect())
+	assert.True(t, p.Slots[3].HasStorage())
+	assert.False(t, p.Slots[4].HasStorage())
+
+	assert.True(t, p.IsNormal(0))
+	assert.Equal(t, "1", p.Tuples[0].Data.Strings()["id"])
+	for idx := 1; idx < 5; idx++ {
+		assert.False(t, p.IsNormal(idx))
+		assert.Nil(t, p.Tuples[idx].Data)
+	}
+
+	p, err = ReadPageWithOptions(page, alignments, ReadOptions{Dead: true})
+	require.NoError(t, err)
+	assert.Equal(t, "4", p.Tuples[3].Data.Strings()["id"])
+	assert.Nil(t, p.Tuples[4].Data)
+}
+
+func TestPageHeader(t *testing.T) {
+	alignments := []AttrAlign{{AttName: "id", TypName: "int4", TypAlign: "i", TypLen: 4}}
+	page := buildPage(DefaultBlockSize)
+	binary.LittleEndian.PutUint32(page[0:], 0x1)
+	binary.LittleEndian.PutUint32(page[4:], 0x16B3740)
+	binary.LittleEndian.PutUint16(page[10:], PD_HAS_FREE_LINES|PD_ALL_VISIBLE)
+	binary.LittleEndian.PutUint32(page[20:], 742)
+
+	p, err := ReadPage(page, alignments)
+	require.NoError(t, err)
+	assert.Equal(t, "1/16B3740", p.Header.LSN().String())
+	assert.True(t, p.Header.HasFreeLines())
+	assert.False(t, p.Header.IsFull())
+	assert.True(t, p.Header.IsAllVisible())
+	assert.Equal(t, DefaultBlockSize, p.Header.PageSize())
+	assert.EqualValues(t, 4, p.Header.LayoutVersion())
+	assert.EqualValues(t, 742, p.Header.GetPruneXid())
+
+	// a 4kB page read as an 8kB block
+	binary.LittleEndian.PutUint16(page[18:], 4096|4)
+	_, err = ReadPage(page, alignments)
+	assert.Error(t, err)
+}
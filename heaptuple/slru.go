@@ -0,0 +1,57 @@
+package heaptuple
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// The SLRU files under pg_xact, pg_subtrans, pg_multixact and pg_commit_ts
+// are arrays of pages cut into segments of slruPagesPerSegment pages, named
+// by the segment number in hex.
+const (
+	slruPageSize        = 1024 * 8
+	slruPagesPerSegment = 32
+)
+
+// errPageNotWritten is returned for a page beyond the end of its segment.
+var errPageNotWritten = errors.New("page not written yet")
+
+// slru reads the pages of one SLRU directory, pages are cached once read.
+type slru struct {
+	dir   string
+	pages map[int64][]byte
+}
+
+func newSlru(dir string) *slru {
+	return &slru{dir: dir, pages: make(map[int64][]byte)}
+}
+
+func (s *slru) segmentPath(segno int64) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%04X", segno))
+}
+
+func (s *slru) readPage(pageno int64) ([]byte, error) {
+	if page, ok := s.pages[pageno]; ok {
+		return page, nil
+	}
+	path := s.segmentPath(pageno / slruPagesPerSegment)
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	page := make([]byte, slruPageSize)
+	n, err := f.ReadAt(page, pageno%slruPagesPerSegment*slruPageSize)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if n < slruPageSize {
+		return nil, fmt.Errorf("%s: page %d, read %d bytes: %w", path, pageno, n, errPageNotWritten)
+	}
+	s.pages[pageno] = page
+	return page, nil
+}
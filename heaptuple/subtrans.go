@@ -0,0 +1,50 @@
+package heaptuple
+
+import (
+	"encoding/binary"
+	"path/filepath"
+)
+
+const subtransXactsPerPage = slruPageSize / 4
+
+// Subtrans maps subtransactions to their parent with pg_subtrans. The file
+// is not WAL-logged and is reset at startup, it only knows the transactions
+// running since then.
+type Subtrans struct {
+	slru *slru
+}
+
+// OpenSubtrans reads the pg_subtrans directory of the cluster at pgdata.
+func OpenSubtrans(pgdata string) *Subtrans {
+	return &Subtrans{slru: newSlru(filepath.Join(pgdata, "pg_subtrans"))}
+}
+
+// Parent is SubTransGetParent, InvalidTransactionId for a top-level
+// transaction.
+func (s *Subtrans) Parent(xid TransactionId) (TransactionId, error) {
+	if !xid.IsNormal() {
+		return InvalidTransactionId, nil
+	}
+	page, err := s.slru.readPage(int64(xid / subtransXactsPerPage))
+	if err != nil {
+		return InvalidTransactionId, err
+	}
+	entry := xid % subtransXactsPerPage
+	return TransactionId(binary.LittleEndian.Uint32(page[entry*4:])), nil
+}
+
+// Topmost is SubTransGetTopmostTransaction, the top-level transaction xid
+// belongs to, xid itself if it is one.
+func (s *Subtrans) Topmost(xid TransactionId) (TransactionId, error) {
+	for {
+		parent, err := s.Parent(xid)
+		if err != nil {
+			return InvalidTransactionId, err
+		}
+		// a parent is always older, anything else is a stale entry
+		if parent == InvalidTransactionId || !parent.Precedes(xid) {
+			return xid, nil
+		}
+		xid = parent
+	}
+}
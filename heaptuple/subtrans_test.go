@@ -0,0 +1,84 @@
+package heaptuple
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeSubtrans lays out the first segment of pg_subtrans under pgdata.
+func writeSubtrans(t *testing.T, pgdata string, parents map[TransactionId]TransactionId) {
+	dir := filepath.Join(pgdata, "pg_subtrans")
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+	segment := make([]byte, slruPageSize)
+	for xid, parent := range parents {
+		binary.LittleEndian.PutUint32(segment[xid*4:], uint32(parent))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "0000"), segment, 0o644))
+}
+
+func TestSubtrans(t *testing.T) {
+	pgdata := t.TempDir()
+	// 20 has the savepoints 21 and, nested, 22; 30 rolled back to 31
+	writeSubtrans(t, pgdata, map[TransactionId]TransactionId{21: 20, 22: 21, 31: 30, 40: 45})
+	writeClog(t, pgdata, map[TransactionId]XidStatus{
+		20: TRANSACTION_STATUS_COMMITTED,
+		21: TRANSACTION_STATUS_SUB_COMMITTED,
+		22: TRANSACTION_STATUS_SUB_COMMITTED,
+		30: TRANSACTION_STATUS_ABORTED,
+		31: TRANSACTION_STATUS_SUB_COMMITTED,
+		40: TRANSACTION_STATUS_SUB_COMMITTED,
+	})
+	s := OpenSubtrans(pgdata)
+
+	parent, err := s.Parent(22)
+	require.NoError(t, err)
+	assert.EqualValues(t, 21, parent)
+	for xid, want := range map[TransactionId]TransactionId{22: 20, 21: 20, 20: 20, 31: 30, 40: 40} {
+		top, err := s.Topmost(xid)
+		require.NoError(t, err)
+		assert.Equal(t, want, top, "xid %d", xid)
+	}
+
+	v := NewVisibility(OpenCLOG(pgdata), nil)
+	status, err := v.Status(22)
+	require.NoError(t, err)
+	assert.Equal(t, TRANSACTION_STATUS_SUB_COMMITTED, status)
+	v.SetSubtrans(s)
+	for xid, want := range map[TransactionId]XidStatus{
+		22: TRANSACTION_STATUS_COMMITTED,
+		31: TRANSACTION_STATUS_ABORTED,
+		40: TRANSACTION_STATUS_ABORTED,
+	} {
+		status, err := v.Status(xid)
+		require.NoError(t, err)
+		assert.Equal(t, want, status, "xid %d", xid)
+	}
+
+	ok, err := v.TupleVisible(TupleHeader{Xmin: 22, Infomask: HEAP_XMAX_INVALID})
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = v.TupleVisible(TupleHeader{Xmin: 31, Infomask: HEAP_XMAX_INVALID})
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	info, err := DescribeXmax(TupleHeader{Xmax: 22}, v)
+	require.NoError(t, err)
+	assert.Equal(t, "updated by xid 22 (committed)", info)
+
+	// wired in by OpenVisibility
+	status, err = OpenVisibility(pgdata, nil).Status(21)
+	require.NoError(t, err)
+	assert.Equal(t, TRANSACTION_STATUS_COMMITTED, status)
+
+	// the snapshot was taken while 20 was running
+	v = NewVisibility(OpenCLOG(pgdata), &Snapshot{Xmin: 20, Xmax: 50, Xip: []TransactionId{20}})
+	v.SetSubtrans(s)
+	ok, err = v.TupleVisible(TupleHeader{Xmin: 22, Infomask: HEAP_XMIN_COMMITTED | HEAP_XMAX_INVALID})
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
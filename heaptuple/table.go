@@ -3,198 +3,267 @@ package heaptuple
 import (
 	"context"
 	"fmt"
-	"os"
-	"path/filepath"
 	"sort"
-	"strconv"
 	"unsafe"
-
-	"github.com/jackc/pgx/v5"
 )
 
 type AttrAlign struct {
-	AttName  string
-	TypName  string
-	TypAlign string
-	TypLen   int
+	AttName    string `json:"name"`
+	TypName    string `json:"type_name"`
+	TypAlign   string `json:"typalign"`
+	TypLen     int    `json:"typlen"`
+	TypOid     uint32 `json:"type_oid"`
+	TypByVal   bool   `json:"typbyval"`
+	TypMod     int32  `json:"typmod"`
+	IsDropped  bool   `json:"dropped,omitempty"`
+	HasMissing bool   `json:"has_missing,omitempty"`
+	MissingVal string `json:"missing_value,omitempty"`
 }
 
 type Table struct {
-	selfPath       string
-	toastPath      string
+	self           Relation
+	toast          Relation
 	selfAttrAlign  []AttrAlign
 	toastAttrAlign []AttrAlign
-	selfFiles      []HeapFile
-	toastFiles     []HeapFile
+	vis            *Visibility
+	commitTs       *CommitTs
 }
 
-func NewTable(table string) (t Table, err error) {
-	ctx := context.Background()
-	url := "postgres://localhost:8432/litianxiang"
-	conn, err := pgx.Connect(ctx, url)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Unable to connect to database: %v\n", err)
-		os.Exit(1)
-
-	}
-	defer conn.Close(context.Background())
-
-	var pgdata string
-	row := conn.QueryRow(ctx, "show data_directory;")
-	err = row.Scan(&pgdata)
+// NewTable reads the heap files of table, its location and layout are
+// answered by src. When src is a DataDirectorySource the table only returns
+// the tuples of the current committed state, see WithVisibility.
+func NewTable(ctx context.Context, src CatalogSource, table string) (t Table, err error) {
+	blockSize, err := src.BlockSize(ctx)
 	if err != nil {
 		return
 	}
-
-	selfPath, toastPath, err := getSelfAndToastAbsPath(ctx, conn, table, pgdata)
+	selfPath, err := src.RelationPath(ctx, table)
 	if err != nil {
 		return
 	}
-	selfAttrAlign, err := getAlign(ctx, conn, table)
+	selfAttrAlign, err := src.AttrAligns(ctx, table)
 	if err != nil {
 		return
 	}
-	toastAttrAlign, err := getAlign(ctx, conn, fmt.Sprintf("pg_toast_%s", filepath.Base(selfPath)))
-	if err != nil {
-		return
-	}
-	selfFile, err := ReadHeapFile(selfPath, 1024*8, selfAttrAlign)
-	if err != nil {
-		return
-	}
-	toastFile, err := ReadHeapFile(toastPath, 1024*8, toastAttrAlign)
+	toast, err := src.ToastRelation(ctx, table)
 	if err != nil {
 		return
 	}
 
-	return Table{
-		selfPath:       selfPath,
-		toastPath:      toastPath,
-		selfAttrAlign:  selfAttrAlign,
-		toastAttrAlign: toastAttrAlign,
-		selfFiles:      []HeapFile{selfFile},
-		toastFiles:     []HeapFile{toastFile},
-	}, nil
-}
+	var (
+		toastPath      string
+		toastAttrAlign []AttrAlign
+	)
+	if toast != "" {
+		toastPath, err = src.RelationPath(ctx, toast)
+		if err != nil {
+			return
+		}
+		toastAttrAlign, err = src.AttrAligns(ctx, toast)
+		if err != nil {
+			return
+		}
+	}
 
-func getSelfAndToastAbsPath(ctx context.Context, conn *pgx.Conn, table, pgdata string) (string, string, error) {
-	var fpath string
-	row := conn.QueryRow(context.Background(), fmt.Sprintf("SELECT pg_relation_filepath('%s')", table))
-	err := row.Scan(&fpath)
+	t.selfAttrAlign = selfAttrAlign
+	t.toastAttrAlign = toastAttrAlign
+	t.self, err = OpenRelation(selfPath, blockSize)
 	if err != nil {
-		return "", "", err
+		return Table{}, err
 	}
-
-	dir, filename := filepath.Split(fpath)
-	row = conn.QueryRow(context.Background(), fmt.Sprintf("SELECT pg_relation_filepath('%s')", fmt.Sprintf("pg_toast.pg_toast_%s", filename)))
-	err = row.Scan(&fpath)
+	if dir, ok := src.(DataDirectorySource); ok && dir.DataDirectory() != "" {
+		t.vis = OpenVisibility(dir.DataDirectory(), nil)
+	}
+	if toastPath == "" {
+		return t, nil
+	}
+	t.toast, err = OpenRelation(toastPath, blockSize)
 	if err != nil {
-		return "", "", err
+		return Table{}, err
 	}
-	toastFilename := fmt.Sprintf("%s", filepath.Base(fpath))
-	fAbsPath := filepath.Join(pgdata, dir, filename)
-	tAbsPath := filepath.Join(pgdata, dir, toastFilename)
-	return fAbsPath, tAbsPath, nil
+	return t, nil
 }
 
-func getAlign(ctx context.Context, conn *pgx.Conn, table string) ([]AttrAlign, error) {
-	var alignSQL = `
-SELECT a.attname, t.typname, t.typalign::text, t.typlen
-  FROM pg_class c
-  JOIN pg_attribute a ON (a.attrelid = c.oid)
-  JOIN pg_type t ON (t.oid = a.atttypid)
- WHERE c.relname = '%s'
-   AND a.attnum >= 0
- ORDER BY a.attnum;
-`
-	rows, err := conn.Query(ctx, fmt.Sprintf(alignSQL, table))
-	if err != nil {
-		return nil, err
+// GetTuples decodes every tuple of the table at once, large tables should be
+// walked with Scan instead. Unless the table has a Visibility, every tuple
+// on disk is returned, dead ones included. Damaged tuples are left out, the rows are then
+// returned along with the error of the first one.
+func (t Table) GetTuples() ([]Row, error) {
+	var ret []Row
+	s := t.Scan()
+	defer s.Close()
+	for s.Next() {
+		ret = append(ret, s.Row())
+	}
+	if err := s.Err(); err != nil {
+		return ret, err
 	}
-	defer rows.Close()
+	if skipped := s.Skipped(); len(skipped) > 0 {
+		return ret, fmt.Errorf("%d damaged tuples skipped, first: %w", len(skipped), skipped[0])
+	}
+	return ret, nil
+}
 
-	var alignments []AttrAlign
-	for rows.Next() {
-		var item AttrAlign
-		err = rows.Scan(&item.AttName, &item.TypName, &item.TypAlign, &item.TypLen)
-		if err != nil {
-			return nil, err
+// detoast replaces the toast pointers of row by the values they point to.
+func (t Table) detoast(row Row, toast *toastReader) error {
+	for idx := range row {
+		d := &row[idx]
+		switch d.Toast {
+		case VARTAG_UNUSED:
+			continue
+		case VARTAG_ONDISK:
+			bytes, err := toast.onDiskTransfer(d.Name, d.Raw[2:])
+			if err != nil {
+				return err
+			}
+			d.Value, err = t.fieldTransfer(d.Name, bytes)
+			if err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("column %q: only support on disk, received %d", d.Name, d.Toast)
 		}
-		alignments = append(alignments, item)
 	}
-	return alignments, nil
+	return nil
 }
 
-func (t Table) GetTuples() []map[string]string {
-	copyMap := func(m map[string]string) map[string]string {
-		ret := make(map[string]string)
-		for k, v := range m {
-			ret[k] = v
-		}
-		return ret
-	}
-	var ret []map[string]string
-	for _, hp := range t.selfFiles {
-		for _, p := range hp.Pages {
-			for _, tp := range p.Tuples {
-				kv := copyMap(tp.Data)
-				for column, toastTyp := range tp.ExtraToastField {
-					switch toastTyp {
-					case VARTAG_UNUSED:
-						continue
-					case VARTAG_ONDISK:
-						bytes := t.onDiskTransfer(column, []byte(kv[column]))
-						kv[column] = t.fieldTransfer(column, bytes)
-					default:
-						panic(fmt.Errorf("only support on disk, received %d", toastTyp))
-					}
-				}
-				ret = append(ret, kv)
+// toastReader fetches toasted values. The chunks are located by chunk_id
+// with an index built on the first toasted value, so the toast relation is
+// read once rather than once per value.
+type toastReader struct {
+	t      Table
+	chunks map[uint32][]ItemPointer
+}
+
+func (t Table) newToastReader() *toastReader {
+	return &toastReader{t: t}
+}
+
+func (r *toastReader) buildIndex() error {
+	r.chunks = make(map[uint32][]ItemPointer)
+	pages := r.t.toast.Pages(MainForkNum, r.t.toastAttrAlign)
+	defer pages.Close()
+	for pages.Next() {
+		page := pages.Page()
+		for idx, tp := range page.Tuples {
+			if !page.IsNormal(idx) || tp.Err != nil {
+				continue
+			}
+			chunkID, _ := tp.Data.Get("chunk_id")
+			if id, ok := chunkID.Value.(uint32); ok {
+				r.chunks[id] = append(r.chunks[id], ItemPointer{Block: pages.BlockNumber(), Offset: uint16(idx + 1)})
 			}
 		}
 	}
-	return ret
+	return pages.Err()
 }
 
-func (t Table) onDiskTransfer(column string, bytes []byte) []byte {
+func (r *toastReader) onDiskTransfer(column string, bytes []byte) ([]byte, error) {
+	if len(bytes) < int(unsafe.Sizeof(ExternalOnDisk{})) {
+		return nil, fmt.Errorf("column %q: toast pointer too short, %d bytes", column, len(bytes))
+	}
 	toastOnDisk := **(**ExternalOnDisk)(unsafe.Pointer(&bytes))
+	if !r.t.toast.HasFork(MainForkNum) {
+		return nil, fmt.Errorf("column %q: toast value %d without a toast relation", column, toastOnDisk.ValueOID)
+	}
+	if r.chunks == nil {
+		if err := r.buildIndex(); err != nil {
+			return nil, err
+		}
+	}
 
 	type sortItem struct {
-		seq     int
-		content string
-	}
-	var buffer []sortItem
-	for _, hp := range t.toastFiles {
-		for _, p := range hp.Pages {
-			for _, tp := range p.Tuples {
-				if tp.Data["chunk_id"] != fmt.Sprintf("%d", toastOnDisk.ValueOID) {
-					continue
-				}
-				seq, err := strconv.Atoi(tp.Data["chunk_seq"])
-				if err != nil {
-					panic("parse chunk_seq failed")
-				}
-				buffer = append(buffer, sortItem{seq: seq, content: tp.Data["chunk_data"]})
+		seq     int32
+		content []byte
+	}
+	var (
+		buffer []sortItem
+		page   Page
+		blkno  = InvalidBlockNumber
+	)
+	// the index lists the chunks in block order, each block is read once
+	for _, ip := range r.chunks[toastOnDisk.ValueOID] {
+		if ip.Block != blkno {
+			raw, err := r.t.toast.ReadBlock(MainForkNum, ip.Block)
+			if err != nil {
+				return nil, err
 			}
-			sort.Slice(buffer, func(i, j int) bool { return buffer[i].seq < buffer[j].seq })
+			page, err = ReadPage(raw, r.t.toastAttrAlign)
+			locate(err, r.t.toast.Path, ip.Block)
+			if err != nil {
+				return nil, err
+			}
+			blkno = ip.Block
+		}
+		tp := page.Tuples[ip.Offset-1]
+		if tp.Err != nil {
+			continue
 		}
+		seq, _ := tp.Data.Get("chunk_seq")
+		data, _ := tp.Data.Get("chunk_data")
+		seqValue, ok := seq.Value.(int32)
+		if !ok {
+			return nil, fmt.Errorf("column %q: chunk_seq of toast value %d is %s", column, toastOnDisk.ValueOID, seq)
+		}
+		content, ok := data.Value.([]byte)
+		if !ok {
+			return nil, fmt.Errorf("column %q: chunk %d of toast value %d has no data", column, seqValue, toastOnDisk.ValueOID)
+		}
+		buffer = append(buffer, sortItem{seq: seqValue, content: content})
 	}
+	if len(buffer) == 0 {
+		return nil, fmt.Errorf("column %q: toast value %d not found", column, toastOnDisk.ValueOID)
+	}
+	sort.Slice(buffer, func(i, j int) bool { return buffer[i].seq < buffer[j].seq })
 	var ret []byte
-	for _, item := range buffer {
-		ret = append(ret, []byte(item.content)...)
+	for idx, item := range buffer {
+		// a damaged chunk was skipped
+		if int(item.seq) != idx {
+			return nil, fmt.Errorf("column %q: toast value %d misses chunk %d", column, toastOnDisk.ValueOID, idx)
+		}
+		ret = append(ret, item.content...)
 	}
-	return ret
+	if toastOnDisk.ExtSize&0x3FFFFFFF >= toastOnDisk.RawSize-4 {
+		return ret, nil
+	}
+	// the chunks hold the compressed varlena without its length word, it
+	// starts with va_tcinfo
+	if len(ret) < 4 {
+		return nil, fmt.Errorf("column %q: compressed toast value %d too short", column, toastOnDisk.ValueOID)
+	}
+	raw := make([]byte, int(toastOnDisk.RawSize-4))
+	if err := Decompress(ret[4:], raw); err != nil {
+		return nil, fmt.Errorf("column %q: toast value %d: %w", column, toastOnDisk.ValueOID, err)
+	}
+	return raw, nil
 }
 
-func (t Table) fieldTransfer(column string, bytes []byte) string {
+func (t Table) fieldTransfer(column string, bytes []byte) (interface{}, error) {
 	for _, item := range t.selfAttrAlign {
 		if item.AttName != column {
 			continue
 		}
-		switch item.TypName {
-		case "text":
-			return string(bytes)
+		value, err := decodeVarlena(item, bytes)
+		if err != nil {
+			return nil, fmt.Errorf("column %q: toasted %w", column, err)
+		}
+		return value, nil
+	}
+	return nil, fmt.Errorf("column %q does not exist", column)
+}
+
+// VerifyChecksums checks the pages of the table and of its toast relation.
+func (t Table) VerifyChecksums() ([]ChecksumReport, error) {
+	var reports []ChecksumReport
+	for _, rel := range []Relation{t.self, t.toast} {
+		if !rel.HasFork(MainForkNum) {
+			continue
+		}
+		report, err := rel.VerifyChecksums()
+		if err != nil {
+			return reports, err
 		}
+		reports = append(reports, report)
 	}
-	panic("not support")
+	return reports, nil
 }
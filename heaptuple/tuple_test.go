@@ -0,0 +1,231 @@
+package heaptuple
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTupleDataDroppedAndMissing(t *testing.T) {
+	alignments := []AttrAlign{
+		{AttName: "id", TypName: "int4", TypAlign: "i", TypLen: 4},
+		{AttName: "........pg.dropped.2........", TypAlign: "i", TypLen: -1, IsDropped: true},
+		{AttName: "extra", TypName: "int4", TypAlign: "i", TypLen: 4, HasMissing: true, MissingVal: "5"},
+		{AttName: "note", TypName: "text", TypAlign: "i", TypLen: -1},
+	}
+
+	cases := []struct {
+		name     string
+		natts    int
+		nulls    []bool
+		data     []byte
+		expected map[string]string
+	}{
+		{
+			name:     "written before the drop and the additions",
+			natts:    2,
+			data:     append(encodeAttrs(alignments, int32(1)), 0x0b, 'g', 'o', 'n', 'e'),
+			expected: map[string]string{"id": "1", "extra": "5", "note": "NULL"},
+		},
+		{
+			name:  "dropped attribute is NULL",
+			natts: 4,
+			nulls: []bool{false, true, false, false},
+			data: append(encodeAttrs([]AttrAlign{alignments[0], alignments[2]}, int32(2), int32(7)),
+				0x07, 'h', 'i'),
+			expected: map[string]string{"id": "2", "extra": "7", "note": "hi"},
+		},
+	}
+	for _, c := range cases {
+		th := TupleHeader{Infomask2: uint16(c.natts)}
+		if c.nulls != nil {
+			th.Infomask |= HEAP_HASNULL
+			th.NullBits = make([]byte, len(c.nulls))
+			for i, isNull := range c.nulls {
+				if !isNull {
+					th.NullBits[i] = 1
+				}
+			}
+		}
+		row, err := ParseTupleData(alignments, &th, c.data)
+		require.NoError(t, err, c.name)
+		assert.Equal(t, c.expected, row.Strings(), c.name)
+	}
+}
+
+func TestParseTupleDataAlignment(t *testing.T) {
+	alignments := []AttrAlign{
+		{AttName: "flag", TypName: "bool", TypAlign: "c", TypLen: 1, TypByVal: true},
+		{AttName: "short", TypName: "text", TypAlign: "i", TypLen: -1},
+		{AttName: "long", TypName: "text", TypAlign: "i", TypLen: -1},
+		{AttName: "label", TypName: "cstring", TypAlign: "c", TypLen: -2},
+		{AttName: "n", TypName: "int4", TypAlign: "i", TypLen: 4, TypByVal: true},
+	}
+	saved := append([]AttrAlign(nil), alignments...)
+	assert.Equal(t, []int{0, -1, -1, -1, -1}, attCacheOffsets(alignments))
+
+	data := []byte{
+		1,         // flag
+		0x05, 'a', // short, unaligned
+		0,                       // padding of long
+		0x18, 0, 0, 0, 'x', 'y', // long, 4-byte header
+		'o', 'k', 0, // label
+		0, 0, 0, // padding of n
+		9, 0, 0, 0,
+	}
+	th := TupleHeader{Infomask2: 5}
+	row, err := ParseTupleData(alignments, &th, data)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"flag": "t", "short": "a", "long": "xy", "label": "ok", "n": "9"}, row.Strings())
+	assert.Equal(t, saved, alignments)
+
+	_, err = ParseTupleData([]AttrAlign{{AttName: "label", TypName: "cstring", TypAlign: "c", TypLen: -2}},
+		&TupleHeader{Infomask2: 1}, []byte("abc"))
+	assert.ErrorIs(t, err, ErrCorruptTuple)
+	_, err = ParseTupleData([]AttrAlign{{AttName: "p", TypName: "point", TypAlign: "d", TypLen: 16, TypByVal: true}},
+		&TupleHeader{Infomask2: 1}, make([]byte, 16))
+	assert.ErrorIs(t, err, ErrUnsupportedType)
+}
+
+func TestDecodeMissingVal(t *testing.T) {
+	array := []byte{
+		1, 0, 0, 0, // ndim
+		0, 0, 0, 0, // dataoffset
+		23, 0, 0, 0, // elemtype
+		1, 0, 0, 0, // dims
+		1, 0, 0, 0, // lbound
+		5, 0, 0, 0,
+	}
+	v, err := decodeMissingVal(string(array), AttrAlign{AttName: "extra", TypName: "int4", TypAlign: "i", TypLen: 4})
+	require.NoError(t, err)
+	assert.Equal(t, "5", v)
+
+	bytea := append(append([]byte(nil), array[:20]...), 7<<2, 0, 0, 0, 0xDE, 0xAD, 0xFF)
+	bytea[8] = 17
+	item := AttrAlign{AttName: "blob", TypName: "bytea", TypAlign: "i", TypLen: -1}
+	v, err = decodeMissingVal(string(bytea), item)
+	require.NoError(t, err)
+	assert.Equal(t, `\xdeadff`, v)
+	value, err := datumFromText(item, v)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0xDE, 0xAD, 0xFF}, value)
+
+	// a damaged catalog may leave atthasmissing on a dropped attribute
+	_, err = decodeMissingVal(string(array), AttrAlign{AttName: "gone", TypAlign: "i", TypLen: 4, IsDropped: true})
+	assert.Error(t, err)
+}
+
+func TestCorruptTuples(t *testing.T) {
+	alignments := []AttrAlign{
+		{AttName: "id", TypName: "int4", TypAlign: "i", TypLen: 4},
+		{AttName: "body", TypName: "text", TypAlign: "i", TypLen: -1},
+	}
+	tuple := func(id int32, body []byte) []byte {
+		return buildTuple(testTuple{xmin: 3, nulls: []bool{false, false}, data: append(encodeAttrs(alignments, id), body...)})
+	}
+	// the varlena claims 100 bytes
+	broken := tuple(2, []byte{100<<1 | 1, 'x'})
+	page := buildPage(DefaultBlockSize, tuple(1, shortVarlena("a")), broken, tuple(3, shortVarlena("c")))
+
+	p, err := ReadPage(page, alignments)
+	require.NoError(t, err)
+	assert.Equal(t, "a", p.Tuples[0].Data.Strings()["body"])
+	assert.Equal(t, "c", p.Tuples[2].Data.Strings()["body"])
+
+	var ce *CorruptionError
+	require.ErrorAs(t, p.Tuples[1].Err, &ce)
+	assert.True(t, errors.Is(ce, ErrCorruptTuple))
+	assert.EqualValues(t, 2, ce.LinePointer)
+	assert.Equal(t, "body", ce.Attribute)
+	assert.Equal(t, int(p.Slots[1].GetTupleOffset())+24+4, ce.Offset)
+	assert.Equal(t, InvalidBlockNumber, ce.Block)
+
+	_, err = ParseTupleData([]AttrAlign{{AttName: "p", TypName: "point", TypAlign: "d", TypLen: 16}},
+		&TupleHeader{Infomask2: 1}, make([]byte, 16))
+	assert.True(t, errors.Is(err, ErrUnsupportedType))
+	_, err = ParseTupleData([]AttrAlign{{AttName: "id", TypName: "int4", TypAlign: "x", TypLen: 4}},
+		&TupleHeader{Infomask2: 1}, make([]byte, 4))
+	assert.True(t, errors.Is(err, ErrUnsupportedType))
+
+	// a bad line pointer and a bad page header
+	setSlot(page, 2, LP_NORMAL, DefaultBlockSize-8, 32)
+	p, err = ReadPage(page, alignments)
+	require.NoError(t, err)
+	assert.True(t, errors.Is(p.Tuples[2].Err, ErrCorruptTuple))
+	page[13] = 0xFF
+	_, err = ReadPage(page, alignments)
+	assert.True(t, errors.Is(err, ErrCorruptPage))
+
+	// the scan goes on past a damaged tuple
+	path := filepath.Join(t.TempDir(), "16384")
+	require.NoError(t, os.WriteFile(path, buildPage(DefaultBlockSize, tuple(1, shortVarlena("a")), broken), 0o644))
+	catalog := NewMemCatalog(DefaultBlockSize)
+	catalog.Add("t", MemRelation{Path: path, AttrAligns: alignments})
+	table, err := NewTable(context.Background(), catalog, "t")
+	require.NoError(t, err)
+	rows, err := table.GetTuples()
+	assert.Equal(t, []map[string]string{{"id": "1", "body": "a"}}, rowStrings(rows))
+	require.ErrorAs(t, err, &ce)
+	assert.Equal(t, path, ce.Path)
+	assert.EqualValues(t, 0, ce.Block)
+}
+
+func TestParseVarlenaBounds(t *testing.T) {
+	for _, bins := range [][]byte{
+		nil,
+		{0x01},
+		{0x01, 0x07},
+		{0x01, VARTAG_ONDISK, 0, 0},
+		{0x10, 0, 0},
+		{0x40, 0, 0, 0, 'a'},
+	} {
+		_, err := ParseVarlena(bins)
+		assert.True(t, errors.Is(err, ErrCorruptTuple), "% x", bins)
+	}
+
+	// compressed, but not pglz data
+	v, err := ParseVarlena([]byte{12<<2 | 0x02, 0, 0, 0, 10, 0, 0, 0, 0x01, 0x00, 0x00, 0x00})
+	require.NoError(t, err)
+	_, err = v.GetData()
+	assert.True(t, errors.Is(err, ErrDecompress))
+}
+
+func TestInfomask(t *testing.T) {
+	th := TupleHeader{
+		Infomask:  HEAP_HASNULL | HEAP_HASVARWIDTH | HEAP_XMIN_COMMITTED | HEAP_XMIN_INVALID | HEAP_XMAX_KEYSHR_LOCK | HEAP_XMAX_EXCL_LOCK | HEAP_UPDATED,
+		Infomask2: 3 | HEAP_HOT_UPDATED | HEAP_KEYS_UPDATED,
+	}
+	assert.True(t, th.HasNullBits())
+	assert.True(t, th.HasVarWidth())
+	assert.False(t, th.HasExternal())
+	assert.True(t, th.XminFrozen())
+	assert.True(t, th.XminCommitted())
+	assert.False(t, th.XminInvalid())
+	assert.True(t, th.XmaxIsShrLocked())
+	assert.False(t, th.XmaxIsExclLocked())
+	assert.False(t, th.XmaxIsLockedOnly())
+	assert.True(t, th.IsUpdated())
+	assert.True(t, th.IsHotUpdated())
+	assert.True(t, th.KeysUpdated())
+	assert.False(t, th.IsHeapOnly())
+	assert.EqualValues(t, 3, th.AttrCnt())
+
+	raw, combined := th.InfomaskFlags()
+	assert.Equal(t, []string{
+		"HEAP_HASNULL", "HEAP_HASVARWIDTH", "HEAP_XMAX_KEYSHR_LOCK", "HEAP_XMAX_EXCL_LOCK",
+		"HEAP_XMIN_COMMITTED", "HEAP_XMIN_INVALID", "HEAP_UPDATED", "HEAP_KEYS_UPDATED", "HEAP_HOT_UPDATED",
+	}, raw)
+	assert.Equal(t, []string{"HEAP_XMAX_SHR_LOCK", "HEAP_XMIN_FROZEN"}, combined)
+
+	// a pre-9.3 row lock
+	th = TupleHeader{Infomask: HEAP_XMAX_EXCL_LOCK}
+	assert.True(t, th.XmaxIsLockedOnly())
+	raw, combined = th.InfomaskFlags()
+	assert.Equal(t, []string{"HEAP_XMAX_EXCL_LOCK"}, raw)
+	assert.Empty(t, combined)
+}
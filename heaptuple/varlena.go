@@ -1,13 +1,14 @@
 package heaptuple
 
 import (
+	"fmt"
 	"unsafe"
 )
 
 type Varlena interface {
 	GetLength() int
 	GetDataLength() int
-	GetData() []byte
+	GetData() ([]byte, error)
 	GetType() EXTERNAL
 }
 
@@ -42,8 +43,8 @@ func (v VarAttrib1B) GetLength() int {
 	return int(v.Header >> 1)
 }
 
-func (v VarAttrib1B) GetData() []byte {
-	return v.Bytes
+func (v VarAttrib1B) GetData() ([]byte, error) {
+	return v.Bytes, nil
 }
 
 func (v VarAttrib1B) GetType() EXTERNAL {
@@ -67,7 +68,8 @@ func (v VarAttrib1BE) GetDataLength() int {
 	case VARTAG_ONDISK:
 		return 16
 	default:
-		panic("invalid tag")
+		// rejected by ParseVarlena
+		return 0
 	}
 }
 
@@ -75,14 +77,25 @@ func (v VarAttrib1BE) GetLength() int {
 	return v.GetDataLength() + 2
 }
 
-func (v VarAttrib1BE) GetData() []byte {
-	return v.Bytes
+func (v VarAttrib1BE) GetData() ([]byte, error) {
+	return v.Bytes, nil
 }
 
 func (v VarAttrib1BE) GetType() EXTERNAL {
 	return v.Tag
 }
 
+// IsCompressed is VARATT_EXTERNAL_IS_COMPRESSED, whether the value pointed
+// to by an on-disk toast pointer is stored compressed.
+func (v VarAttrib1BE) IsCompressed() bool {
+	if v.Tag != VARTAG_ONDISK || len(v.Bytes) < int(unsafe.Sizeof(ExternalOnDisk{})) {
+		return false
+	}
+	bytes := v.Bytes
+	pointer := **(**ExternalOnDisk)(unsafe.Pointer(&bytes))
+	return pointer.ExtSize&0x3FFFFFFF < pointer.RawSize-4
+}
+
 type VarAttrib4B struct {
 	Header  uint32
 	RawSize uint32
@@ -100,16 +113,18 @@ func (v VarAttrib4B) GetLength() int {
 	return int(v.Header >> 2)
 }
 
-func (v VarAttrib4B) GetData() []byte {
+// GetData returns the value, decompressed if needed.
+func (v VarAttrib4B) GetData() ([]byte, error) {
 	if !v.IsCompressed() {
-		return v.Bytes
+		return v.Bytes, nil
 	}
-	ret := make([]byte, int(v.RawSize))
-	err := Decompress(v.Bytes, ret)
-	if err != nil {
-		panic(err)
+	// the low 30 bits, the high 2 bits are the compression method
+	rawSize := v.RawSize & 0x3FFFFFFF
+	ret := make([]byte, int(rawSize))
+	if err := Decompress(v.Bytes, ret); err != nil {
+		return nil, err
 	}
-	return ret
+	return ret, nil
 }
 
 func (v VarAttrib4B) IsCompressed() bool {
@@ -120,53 +135,61 @@ func (v VarAttrib4B) GetType() EXTERNAL {
 	return VARTAG_UNUSED
 }
 
-func ParseVarlena(bins []byte) Varlena {
+// ParseVarlena decodes the varlena at the start of bins, a header that does
+// not fit in bins fails with ErrCorruptTuple.
+func ParseVarlena(bins []byte) (Varlena, error) {
+	if len(bins) == 0 {
+		return nil, fmt.Errorf("%w: varlena header beyond the end of the tuple", ErrCorruptTuple)
+	}
 	header := bins[0]
 	switch {
 	case header&0x01 == 0x01 && header != 0x01:
 		tmp := VarAttrib1B{
 			Header: header,
 		}
-		cnt := tmp.GetDataLength()
-		bins = bins[1:]
-		for i := 0; i < cnt; i++ {
-			tmp.Bytes = append(tmp.Bytes, bins[i])
+		if tmp.GetLength() > len(bins) {
+			return nil, fmt.Errorf("%w: varlena of %d bytes, %d left", ErrCorruptTuple, tmp.GetLength(), len(bins))
 		}
-		return tmp
+		tmp.Bytes = append(tmp.Bytes, bins[1:tmp.GetLength()]...)
+		return tmp, nil
 	case header == 0x01:
+		if len(bins) < 2 {
+			return nil, fmt.Errorf("%w: external varlena without tag", ErrCorruptTuple)
+		}
 		tmp := VarAttrib1BE{
 			Header: header,
 			Tag:    bins[1],
 		}
-		cnt := tmp.GetDataLength()
-		bins = bins[2:]
-		for i := 0; i < cnt; i++ {
-			tmp.Bytes = append(tmp.Bytes, bins[i])
+		switch tmp.Tag {
+		case VARTAG_INDIRECT, VARTAG_EXPANDED_RO, VARTAG_EXPANDED_RW, VARTAG_ONDISK:
+		default:
+			return nil, fmt.Errorf("%w: invalid external varlena tag %d", ErrCorruptTuple, tmp.Tag)
 		}
-		return tmp
-	case header&0x03 == 0x00:
-		tmp := VarAttrib4B{
-			Header: **(**uint32)(unsafe.Pointer(&bins)),
+		if tmp.GetLength() > len(bins) {
+			return nil, fmt.Errorf("%w: varlena of %d bytes, %d left", ErrCorruptTuple, tmp.GetLength(), len(bins))
 		}
-		cnt := tmp.GetDataLength()
-		bins = bins[4:]
-		for i := 0; i < cnt; i++ {
-			tmp.Bytes = append(tmp.Bytes, bins[i])
+		tmp.Bytes = append(tmp.Bytes, bins[2:tmp.GetLength()]...)
+		return tmp, nil
+	case header&0x03 == 0x00, header&0x03 == 0x02:
+		if len(bins) < 4 {
+			return nil, fmt.Errorf("%w: varlena header of 4 bytes, %d left", ErrCorruptTuple, len(bins))
 		}
-		return tmp
-	case header&0x03 == 0x02:
 		tmp := VarAttrib4B{
 			Header: **(**uint32)(unsafe.Pointer(&bins)),
 		}
-		rawSize := bins[4:8]
-		tmp.RawSize = **(**uint32)(unsafe.Pointer(&rawSize))
-
-		cnt := tmp.GetDataLength()
-		bins = bins[8:]
-		for i := 0; i < cnt; i++ {
-			tmp.Bytes = append(tmp.Bytes, bins[i])
+		hdrLen := 4
+		if tmp.IsCompressed() {
+			hdrLen = 8
+		}
+		if tmp.GetLength() < hdrLen || tmp.GetLength() > len(bins) {
+			return nil, fmt.Errorf("%w: varlena of %d bytes, %d left", ErrCorruptTuple, tmp.GetLength(), len(bins))
+		}
+		if tmp.IsCompressed() {
+			rawSize := bins[4:8]
+			tmp.RawSize = **(**uint32)(unsafe.Pointer(&rawSize))
 		}
-		return tmp
+		tmp.Bytes = append(tmp.Bytes, bins[hdrLen:tmp.GetLength()]...)
+		return tmp, nil
 	}
-	panic("no support sub varlena type")
+	return nil, fmt.Errorf("%w: unknown varlena header 0x%02x", ErrCorruptTuple, header)
 }
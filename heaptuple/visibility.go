@@ -0,0 +1,221 @@
+package heaptuple
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Snapshot is an MVCC snapshot as returned by pg_current_snapshot():
+// transactions before Xmin are finished, those from Xmax on and those of Xip
+// are still running.
+type Snapshot struct {
+	Xmin TransactionId
+	Xmax TransactionId
+	Xip  []TransactionId
+}
+
+// ParseSnapshot parses the text form of pg_snapshot, xmin:xmax:xip1,xip2.
+func ParseSnapshot(s string) (*Snapshot, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("invalid snapshot %q", s)
+	}
+	parseXid := func(s string) (TransactionId, error) {
+		xid, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid snapshot xid %q", s)
+		}
+		// pg_snapshot carries epoch-extended xids
+		return TransactionId(xid), nil
+	}
+
+	var (
+		ret Snapshot
+		err error
+	)
+	if ret.Xmin, err = parseXid(parts[0]); err != nil {
+		return nil, err
+	}
+	if ret.Xmax, err = parseXid(parts[1]); err != nil {
+		return nil, err
+	}
+	if parts[2] == "" {
+		return &ret, nil
+	}
+	for _, item := range strings.Split(parts[2], ",") {
+		xid, err := parseXid(item)
+		if err != nil {
+			return nil, err
+		}
+		ret.Xip = append(ret.Xip, xid)
+	}
+	return &ret, nil
+}
+
+// running is XidInMVCCSnapshot, whether xid was still running when the
+// snapshot was taken. The nil snapshot sees every finished transaction.
+func (s *Snapshot) running(xid TransactionId) bool {
+	if s == nil {
+		return false
+	}
+	if xid.Precedes(s.Xmin) {
+		return false
+	}
+	if !xid.Precedes(s.Xmax) {
+		return true
+	}
+	for _, item := range s.Xip {
+		if item == xid {
+			return true
+		}
+	}
+	return false
+}
+
+// Visibility decides which tuples a snapshot sees, the rules are those of
+// HeapTupleSatisfiesMVCC for a reader that never wrote to the table: the hint
+// bits are trusted first, then the commit log is consulted.
+type Visibility struct {
+	clog     *CLOG
+	multi    *MultiXact
+	subtrans *Subtrans
+	snapshot *Snapshot
+}
+
+// NewVisibility evaluates tuples against snapshot, nil stands for the current
+// committed state: the work of every committed transaction is seen, that of
+// running, aborted or crashed ones is not.
+func NewVisibility(clog *CLOG, snapshot *Snapshot) *Visibility {
+	return &Visibility{clog: clog, snapshot: snapshot}
+}
+
+// OpenVisibility evaluates tuples against snapshot with the commit status,
+// the MultiXactIds and the subtransactions of the cluster at pgdata.
+func OpenVisibility(pgdata string, snapshot *Snapshot) *Visibility {
+	v := NewVisibility(OpenCLOG(pgdata), snapshot)
+	v.SetMultiXact(OpenMultiXact(pgdata))
+	v.SetSubtrans(OpenSubtrans(pgdata))
+	return v
+}
+
+// SetMultiXact resolves the updater of tuples whose xmax is a MultiXactId,
+// without it they are taken as not deleted.
+func (v *Visibility) SetMultiXact(m *MultiXact) {
+	v.multi = m
+}
+
+// SetSubtrans resolves subtransactions to their top-level transaction,
+// without it a sub-committed subtransaction is taken as not committed and
+// the snapshot must list subtransactions themselves.
+func (v *Visibility) SetSubtrans(s *Subtrans) {
+	v.subtrans = s
+}
+
+// Status is the commit status of xid, that of its parent for a
+// sub-committed subtransaction, like TransactionIdDidCommit.
+func (v *Visibility) Status(xid TransactionId) (XidStatus, error) {
+	status, err := v.clog.Status(xid)
+	if err != nil || status != TRANSACTION_STATUS_SUB_COMMITTED || v.subtrans == nil {
+		return status, err
+	}
+	parent, err := v.subtrans.Parent(xid)
+	if err != nil {
+		return 0, err
+	}
+	if parent == InvalidTransactionId || !parent.Precedes(xid) {
+		return TRANSACTION_STATUS_ABORTED, nil
+	}
+	return v.Status(parent)
+}
+
+// running is XidInMVCCSnapshot, a subtransaction runs as long as its
+// top-level transaction, the only one pg_current_snapshot() lists.
+func (v *Visibility) running(xid TransactionId) (bool, error) {
+	if v.snapshot.running(xid) {
+		return true, nil
+	}
+	if v.snapshot == nil || v.subtrans == nil || xid.Precedes(v.snapshot.Xmin) {
+		return false, nil
+	}
+	top, err := v.subtrans.Topmost(xid)
+	if err != nil || top == xid {
+		return false, err
+	}
+	return v.snapshot.running(top), nil
+}
+
+// committed reports whether xid committed and is seen by the snapshot.
+func (v *Visibility) committed(xid TransactionId) (bool, error) {
+	running, err := v.running(xid)
+	if running || err != nil {
+		return false, err
+	}
+	status, err := v.Status(xid)
+	if err != nil {
+		return false, err
+	}
+	return status == TRANSACTION_STATUS_COMMITTED, nil
+}
+
+// TupleVisible reports whether the tuple with header th is seen.
+func (v *Visibility) TupleVisible(th TupleHeader) (bool, error) {
+	xmin, xmax := TransactionId(th.Xmin), TransactionId(th.Xmax)
+	switch {
+	case th.XminInvalid():
+		return false, nil
+	case th.XminFrozen():
+	case th.XminCommitted():
+		running, err := v.running(xmin)
+		if running || err != nil {
+			return false, err
+		}
+	default:
+		ok, err := v.committed(xmin)
+		if !ok || err != nil {
+			return false, err
+		}
+	}
+
+	// the inserter is seen, so is the tuple unless its deleter is too
+	if th.XmaxInvalid() || th.XmaxIsLockedOnly() {
+		return true, nil
+	}
+	if th.XmaxIsMulti() {
+		if v.multi == nil {
+			return true, nil
+		}
+		updater, err := v.multi.UpdateXid(MultiXactId(xmax))
+		if err != nil || updater == InvalidTransactionId {
+			return err == nil, err
+		}
+		ok, err := v.committed(updater)
+		if err != nil {
+			return false, err
+		}
+		return !ok, nil
+	}
+	if th.XmaxCommitted() {
+		return v.running(xmax)
+	}
+	ok, err := v.committed(xmax)
+	if err != nil {
+		return false, err
+	}
+	return !ok, nil
+}
+
+// WithVisibility returns the table whose scans only return the tuples seen
+// by v. With a nil v every tuple on disk is returned, deleted, aborted and
+// superseded versions included.
+func (t Table) WithVisibility(v *Visibility) Table {
+	t.vis = v
+	return t
+}
+
+func (t Table) multiXact() *MultiXact {
+	if t.vis == nil {
+		return nil
+	}
+	return t.vis.multi
+}
@@ -0,0 +1,162 @@
+package heaptuple
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeClog lays out a pg_xact directory under pgdata with the status of
+// xids, the others are in progress.
+func writeClog(t *testing.T, pgdata string, status map[TransactionId]XidStatus) {
+	dir := filepath.Join(pgdata, "pg_xact")
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+	segments := make(map[int64][]byte)
+	for xid, st := range status {
+		pageno := int64(xid / clogXactsPerPage)
+		segno := pageno / slruPagesPerSegment
+		if segments[segno] == nil {
+			segments[segno] = make([]byte, slruPagesPerSegment*slruPageSize)
+		}
+		byteno := pageno%slruPagesPerSegment*slruPageSize + int64(xid%clogXactsPerPage/clogXactsPerByte)
+		segments[segno][byteno] |= byte(st) << (xid % clogXactsPerByte * clogBitsPerXact)
+	}
+	s := newSlru(dir)
+	for segno, content := range segments {
+		require.NoError(t, os.WriteFile(s.segmentPath(segno), content, 0o644))
+	}
+}
+
+func TestCLOG(t *testing.T) {
+	pgdata := t.TempDir()
+	writeClog(t, pgdata, map[TransactionId]XidStatus{
+		3:       TRANSACTION_STATUS_COMMITTED,
+		4:       TRANSACTION_STATUS_ABORTED,
+		6:       TRANSACTION_STATUS_SUB_COMMITTED,
+		1048577: TRANSACTION_STATUS_COMMITTED,
+	})
+	assert.FileExists(t, filepath.Join(pgdata, "pg_xact", "0001"))
+
+	clog := OpenCLOG(pgdata)
+	for xid, want := range map[TransactionId]XidStatus{
+		InvalidTransactionId: TRANSACTION_STATUS_ABORTED,
+		FrozenTransactionId:  TRANSACTION_STATUS_COMMITTED,
+		3:                    TRANSACTION_STATUS_COMMITTED,
+		4:                    TRANSACTION_STATUS_ABORTED,
+		5:                    TRANSACTION_STATUS_IN_PROGRESS,
+		6:                    TRANSACTION_STATUS_SUB_COMMITTED,
+		1048577:              TRANSACTION_STATUS_COMMITTED,
+	} {
+		status, err := clog.Status(xid)
+		require.NoError(t, err)
+		assert.Equal(t, want, status, "xid %d", xid)
+	}
+	_, err := clog.Status(1 << 30)
+	assert.Error(t, err)
+
+	assert.True(t, TransactionId(3).Precedes(4))
+	assert.True(t, TransactionId(0xFFFFFFF0).Precedes(5))
+	assert.True(t, FrozenTransactionId.Precedes(0xFFFFFFF0))
+}
+
+func TestParseSnapshot(t *testing.T) {
+	s, err := ParseSnapshot("10:20:12,15")
+	require.NoError(t, err)
+	assert.Equal(t, &Snapshot{Xmin: 10, Xmax: 20, Xip: []TransactionId{12, 15}}, s)
+	assert.False(t, s.running(9))
+	assert.True(t, s.running(12))
+	assert.False(t, s.running(13))
+	assert.True(t, s.running(20))
+
+	s, err = ParseSnapshot("10:10:")
+	require.NoError(t, err)
+	assert.Empty(t, s.Xip)
+	_, err = ParseSnapshot("10:x:")
+	assert.Error(t, err)
+}
+
+func TestTupleVisible(t *testing.T) {
+	pgdata := t.TempDir()
+	writeClog(t, pgdata, map[TransactionId]XidStatus{
+		10: TRANSACTION_STATUS_COMMITTED,
+		11: TRANSACTION_STATUS_ABORTED,
+		13: TRANSACTION_STATUS_COMMITTED,
+		14: TRANSACTION_STATUS_SUB_COMMITTED,
+	})
+	current := NewVisibility(OpenCLOG(pgdata), nil)
+	// 13 committed after the snapshot was taken
+	past := NewVisibility(OpenCLOG(pgdata), &Snapshot{Xmin: 12, Xmax: 14, Xip: []TransactionId{13}})
+
+	cases := []struct {
+		name          string
+		th            TupleHeader
+		current, past bool
+	}{
+		{"inserted", TupleHeader{Xmin: 10, Infomask: HEAP_XMAX_INVALID}, true, true},
+		{"inserted, hinted", TupleHeader{Xmin: 10, Infomask: HEAP_XMIN_COMMITTED | HEAP_XMAX_INVALID}, true, true},
+		{"frozen", TupleHeader{Xmin: 13, Infomask: HEAP_XMIN_FROZEN | HEAP_XMAX_INVALID}, true, true},
+		{"insert aborted", TupleHeader{Xmin: 11}, false, false},
+		{"insert aborted, hinted", TupleHeader{Xmin: 10, Infomask: HEAP_XMIN_INVALID}, false, false},
+		{"insert running", TupleHeader{Xmin: 12}, false, false},
+		{"insert sub-committed", TupleHeader{Xmin: 14}, false, false},
+		{"insert after snapshot", TupleHeader{Xmin: 13}, true, false},
+		{"deleted", TupleHeader{Xmin: 10, Xmax: 10}, false, false},
+		{"deleted, hinted", TupleHeader{Xmin: 10, Xmax: 10, Infomask: HEAP_XMAX_COMMITTED}, false, false},
+		{"delete aborted", TupleHeader{Xmin: 10, Xmax: 11}, true, true},
+		{"delete running", TupleHeader{Xmin: 10, Xmax: 12}, true, true},
+		{"delete after snapshot", TupleHeader{Xmin: 10, Xmax: 13}, false, true},
+		{"delete after snapshot, hinted", TupleHeader{Xmin: 10, Xmax: 13, Infomask: HEAP_XMAX_COMMITTED}, false, true},
+		{"locked", TupleHeader{Xmin: 10, Xmax: 13, Infomask: HEAP_XMAX_LOCK_ONLY | HEAP_XMAX_EXCL_LOCK}, true, true},
+	}
+	for _, c := range cases {
+		ok, err := current.TupleVisible(c.th)
+		require.NoError(t, err)
+		assert.Equal(t, c.current, ok, "%s, current", c.name)
+		ok, err = past.TupleVisible(c.th)
+		require.NoError(t, err)
+		assert.Equal(t, c.past, ok, "%s, past", c.name)
+	}
+}
+
+func TestScanWithVisibility(t *testing.T) {
+	pgdata := t.TempDir()
+	writeClog(t, pgdata, map[TransactionId]XidStatus{
+		10: TRANSACTION_STATUS_COMMITTED,
+		11: TRANSACTION_STATUS_ABORTED,
+	})
+	alignments := []AttrAlign{{AttName: "id", TypName: "int4", TypAlign: "i", TypLen: 4}}
+	tuple := func(id int32, xmin, xmax uint32) []byte {
+		return buildTuple(testTuple{xmin: xmin, xmax: xmax, nulls: []bool{false}, data: encodeAttrs(alignments, id)})
+	}
+	path := filepath.Join(pgdata, "16384")
+	require.NoError(t, os.WriteFile(path, buildPage(DefaultBlockSize,
+		tuple(1, 10, 0), tuple(2, 10, 10), tuple(3, 11, 0), tuple(4, 10, 11), tuple(5, 12, 0),
+	), 0o644))
+
+	catalog := NewMemCatalog(DefaultBlockSize)
+	catalog.Add("t", MemRelation{Path: path, AttrAligns: alignments})
+	table, err := NewTable(context.Background(), catalog, "t")
+	require.NoError(t, err)
+	rows, err := table.GetTuples()
+	require.NoError(t, err)
+	assert.Len(t, rows, 5)
+
+	rows, err = table.WithVisibility(NewVisibility(OpenCLOG(pgdata), nil)).GetTuples()
+	require.NoError(t, err)
+	assert.Equal(t, []map[string]string{{"id": "1"}, {"id": "4"}}, rowStrings(rows))
+
+	// knowing the data directory the current committed state is the default
+	catalog.SetDataDirectory(pgdata)
+	table, err = NewTable(context.Background(), catalog, "t")
+	require.NoError(t, err)
+	rows, err = table.GetTuples()
+	require.NoError(t, err)
+	assert.Equal(t, []map[string]string{{"id": "1"}, {"id": "4"}}, rowStrings(rows))
+	rows, err = table.WithVisibility(nil).GetTuples()
+	require.NoError(t, err)
+	assert.Len(t, rows, 5)
+}